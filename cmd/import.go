@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"clean-go-rest-api/internal/adapter/repository"
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/infrastructure/db"
+	"clean-go-rest-api/internal/usecase"
+)
+
+// runImport implements the "import" subcommand, which loads a CSV file of
+// users straight into the database and prints the report as JSON. It
+// returns the process exit code.
+func runImport(args []string, logger logger.ILogger) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without writing anything")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: import [-dry-run] FILE (- reads standard input)")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var csv io.Reader = os.Stdin
+	if path := flags.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Unable to open import file: %s", err.Error()))
+			return 1
+		}
+		defer file.Close()
+		csv = file
+	}
+
+	cfg := loadConfig(logger)
+	runMigrations(cfg, logger)
+	dbConn := initDB(cfg, logger)
+	defer dbConn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db_executor := repository.NewDBExecutorAdapter(dbConn)
+	repo := repository.NewPostgresUserRepository(db_executor, logger, db.NewNotifier())
+	useCase := usecase.NewUserUseCase(
+		repo, repository.NewPostgresUnitOfWork(db_executor), repository.NewPostgresOutboxRepository(db_executor),
+	)
+	report, err := useCase.Import(ctx, dto.ImportUsersRequest{CSV: csv, DryRun: *dryRun})
+	if err != nil {
+		logger.Error(fmt.Sprintf("Import failed: %s", err.Error()))
+		return 1
+	}
+
+	logger.Info(fmt.Sprintf("Imported %d users, rejected %d", report.Created, len(report.Rejected)))
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	return 0
+}
@@ -12,7 +12,9 @@ import (
 	"time"
 
 	"clean-go-rest-api/internal/adapter/handler"
+	"clean-go-rest-api/internal/adapter/publisher"
 	"clean-go-rest-api/internal/adapter/repository"
+	"clean-go-rest-api/internal/adapter/webhook"
 	"clean-go-rest-api/internal/config"
 	"clean-go-rest-api/internal/crosscutting/logger"
 	"clean-go-rest-api/internal/infrastructure/db"
@@ -84,20 +86,147 @@ func initDB(cfg *config.Config, logger logger.ILogger) *sql.DB {
 	return dbConn
 }
 
-func setupRouter(dbConn *sql.DB, logger logger.ILogger) *mux.Router {
+func loadLocation(cfg *config.Config, logger logger.ILogger) *time.Location {
+	location, err := cfg.Location()
+	if err != nil {
+		logger.Error(
+			fmt.Sprintf("Unknown time zone %q, rendering times in UTC: %s", cfg.TimeZone, err.Error()),
+		)
+	}
+	return location
+}
+
+func setupRouter(
+	dbConn *sql.DB, cfg *config.Config, events usecase.IUserEventFeed, location *time.Location, logger logger.ILogger,
+) *mux.Router {
 	db_executor := repository.NewDBExecutorAdapter(dbConn)
-	repo := repository.NewPostgresUserRepository(db_executor)
-	userUseCase := usecase.NewUserUseCase(repo)
+	repo := repository.NewPostgresUserRepository(db_executor, logger, db.NewNotifier())
+	userUseCase := usecase.NewUserUseCase(
+		repo, repository.NewPostgresUnitOfWork(db_executor), repository.NewPostgresOutboxRepository(db_executor),
+	)
+	idempotencyUseCase := usecase.NewIdempotencyUseCase(
+		repository.NewPostgresIdempotencyRepository(db_executor),
+		cfg.Idempotency.KeyTTL, cfg.Idempotency.KeyLease,
+	)
 
 	router := mux.NewRouter()
-	handler.NewUserHandler(userUseCase, logger).RegisterRoutes(router)
+	router.Use(handler.RequestIDMiddleware)
+	handler.NewUserHandler(userUseCase, idempotencyUseCase, events, logger, location).RegisterRoutes(router)
+	handler.NewWebhookHandler(
+		usecase.NewWebhookUseCase(repository.NewPostgresWebhookRepository(db_executor)), logger, location,
+	).RegisterRoutes(router)
 	handler.NewHealthCheckHandler(dbConn).RegisterRoutes(router)
 
 	return router
 }
 
-func startServer(router *mux.Router, port int, logger logger.ILogger) *http.Server {
-	httpServer := server.StartServer(router, port, logger)
+func startUserPurger(
+	ctx context.Context, dbConn *sql.DB, cfg *config.Config, logger logger.ILogger,
+) {
+	repo := repository.NewPostgresUserRepository(repository.NewDBExecutorAdapter(dbConn), logger, db.NewNotifier())
+	purger := usecase.NewUserPurger(repo, logger, cfg.Purge.UserRetention, cfg.Purge.Interval)
+	go purger.Run(ctx)
+
+	logger.Info(fmt.Sprintf(
+		"Purging users deleted more than %s ago every %s", cfg.Purge.UserRetention, cfg.Purge.Interval,
+	))
+}
+
+func startIdempotencyKeyPurger(
+	ctx context.Context, dbConn *sql.DB, cfg *config.Config, logger logger.ILogger,
+) {
+	repo := repository.NewPostgresIdempotencyRepository(repository.NewDBExecutorAdapter(dbConn))
+	go usecase.NewIdempotencyKeyPurger(repo, logger, cfg.Idempotency.PurgeInterval).Run(ctx)
+
+	logger.Info(fmt.Sprintf(
+		"Keeping idempotency keys for %s, purging them every %s",
+		cfg.Idempotency.KeyTTL, cfg.Idempotency.PurgeInterval,
+	))
+}
+
+// startOutboxDispatcher publishes events to the log, to webhooks and, by
+// announcing them to every instance, to the feeds of the user event streams.
+func startOutboxDispatcher(
+	ctx context.Context, dbConn *sql.DB, cfg *config.Config, logger logger.ILogger,
+) *usecase.OutboxDispatcher {
+	db_executor := repository.NewDBExecutorAdapter(dbConn)
+	dispatcher := usecase.NewOutboxDispatcher(
+		repository.NewPostgresUnitOfWork(db_executor),
+		repository.NewPostgresOutboxRepository(db_executor),
+		publisher.NewMultiPublisher(
+			publisher.NewLogPublisher(logger),
+			usecase.NewWebhookPublisher(repository.NewPostgresWebhookRepository(db_executor)),
+			repository.NewPostgresEventPublisher(db_executor, db.NewNotifier()),
+		),
+		logger,
+		cfg.Outbox.DispatchInterval,
+		cfg.Outbox.MaxAttempts,
+	)
+	go dispatcher.Run(ctx)
+
+	logger.Info(fmt.Sprintf(
+		"Dispatching outbox events every %s, giving up after %d attempts",
+		cfg.Outbox.DispatchInterval, cfg.Outbox.MaxAttempts,
+	))
+	return dispatcher
+}
+
+// startUserChangeListener listens to the user changes made through every
+// instance. Each change wakes dispatcher, so that the events recorded with
+// it are published right away, whichever instance made it. The events
+// published, by any instance, are followed by events.
+func startUserChangeListener(
+	ctx context.Context, cfg *config.Config, dispatcher *usecase.OutboxDispatcher, events *usecase.UserEventFeed,
+	logger logger.ILogger,
+) {
+	dbParametersDefault := fmt.Sprintf("sslmode=disable TimeZone=%s", cfg.TimeZone)
+	cfg.DB.Parameters = dbParametersDefault + " application_name=go_rest_api_listener"
+
+	listener := db.NewListener(cfg.DBConnectionString(), logger)
+	changes, cancel := listener.Subscribe()
+	published, cancelPublished := listener.Subscribe()
+	go listener.Run(ctx)
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case change := <-changes:
+				if change.Event == nil {
+					dispatcher.Wake()
+				}
+			}
+		}
+	}()
+	go func() {
+		defer cancelPublished()
+		events.Follow(ctx, published)
+	}()
+
+	logger.Info(fmt.Sprintf("Listening to user changes on %s and %s", db.UserChangesChannel, db.UserEventsChannel))
+}
+
+func startWebhookDeliverer(
+	ctx context.Context, dbConn *sql.DB, cfg *config.Config, logger logger.ILogger,
+) {
+	repo := repository.NewPostgresWebhookRepository(repository.NewDBExecutorAdapter(dbConn))
+	deliverer := usecase.NewWebhookDeliverer(
+		repo, webhook.NewHTTPSender(&http.Client{}), logger,
+		cfg.Webhook.DeliveryInterval, cfg.Webhook.Timeout, cfg.Webhook.MaxAttempts,
+	)
+	go deliverer.Run(ctx)
+
+	logger.Info(fmt.Sprintf(
+		"Delivering webhooks every %s, giving up after %d attempts",
+		cfg.Webhook.DeliveryInterval, cfg.Webhook.MaxAttempts,
+	))
+}
+
+func startServer(
+	baseCtx context.Context, router *mux.Router, port int, logger logger.ILogger,
+) *http.Server {
+	httpServer := server.StartServer(baseCtx, router, port, logger)
 
 	logger.Info(fmt.Sprintf("Server running on port %d", port))
 
@@ -106,6 +235,9 @@ func startServer(router *mux.Router, port int, logger logger.ILogger) *http.Serv
 
 func main() {
 	logger := initLogger()
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:], logger))
+	}
 	logger.Info("Starting API application")
 
 	cfg := loadConfig(logger)
@@ -113,13 +245,30 @@ func main() {
 	runMigrations(cfg, logger)
 
 	dbConn := initDB(cfg, logger)
-	router := setupRouter(dbConn, logger)
-	httpServer := startServer(router, cfg.ServerPort, logger)
+	userEvents := usecase.NewUserEventFeed(
+		repository.NewPostgresOutboxRepository(repository.NewDBExecutorAdapter(dbConn)), logger,
+		usecase.UserEventReplaySize,
+	)
+	router := setupRouter(dbConn, cfg, userEvents, loadLocation(cfg, logger), logger)
+
+	workersCtx, cancelWorkers := context.WithCancel(context.Background())
+	startUserPurger(workersCtx, dbConn, cfg, logger)
+	startIdempotencyKeyPurger(workersCtx, dbConn, cfg, logger)
+	dispatcher := startOutboxDispatcher(workersCtx, dbConn, cfg, logger)
+	startUserChangeListener(workersCtx, cfg, dispatcher, userEvents, logger)
+	startWebhookDeliverer(workersCtx, dbConn, cfg, logger)
+
+	serverCtx, cancelServerCtx := context.WithCancel(context.Background())
+	httpServer := startServer(serverCtx, router, cfg.ServerPort, logger)
+	// Event streams never go idle on their own: ending them lets Shutdown
+	// complete without waiting out its timeout.
+	httpServer.RegisterOnShutdown(userEvents.Close)
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 	logger.Info("Shutting down server...")
+	cancelWorkers()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := httpServer.Shutdown(ctx); err != nil {
@@ -127,4 +276,6 @@ func main() {
 	} else {
 		logger.Info("Server exited gracefully")
 	}
+	// Abort whatever is still running once the grace period is over.
+	cancelServerCtx()
 }
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// cacheControl lets clients keep responses but makes them revalidate with
+// If-None-Match before every reuse.
+const cacheControl = "private, no-cache"
+
+var (
+	errPreconditionRequired = errors.New("If-Match header is required")
+	errETagMismatch         = errors.New("If-Match does not match any version of this resource")
+)
+
+// userETag is the strong entity tag of a user at a given version.
+func userETag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// weakETag tags a representation that is only semantically stable, such as
+// a search result, by hashing its payload.
+func weakETag(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ifNoneMatch reports whether the client already holds etag. If-None-Match
+// uses weak comparison, so W/ prefixes are ignored on both sides.
+func ifNoneMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConditionalJSON answers a GET with payload, or with 304 Not Modified
+// when the client's copy is still current.
+func writeConditionalJSON(w http.ResponseWriter, r *http.Request, etag string, payload []byte) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	if ifNoneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(payload, '\n'))
+}
+
+// ifMatchVersions reads the versions a write is conditioned on, one per
+// entity tag listed in If-Match. "*" matches any version and is reported as
+// none. Tags this API never issued (weak or non numeric ones) can never
+// match, as RFC 9110 requires strong comparison, so they are left out.
+func ifMatchVersions(r *http.Request) ([]int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return nil, errPreconditionRequired
+	}
+	if header == "*" {
+		return nil, nil
+	}
+
+	var versions []int64
+	for _, candidate := range strings.Split(header, ",") {
+		tag := strings.TrimSpace(candidate)
+		if strings.HasPrefix(tag, "W/") || len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil || version <= 0 {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return nil, errETagMismatch
+	}
+	return versions, nil
+}
+
+// writeIfMatchError answers a write whose If-Match header is missing or
+// can never match.
+func writeIfMatchError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errPreconditionRequired) {
+		writeProblem(w, r, http.StatusPreconditionRequired, err.Error())
+		return
+	}
+	writeProblem(w, r, http.StatusPreconditionFailed, err.Error())
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ifMatchTestCase struct {
+	testName string
+	header   string
+	versions []int64
+	expected error
+}
+
+func TestIfMatchVersions(t *testing.T) {
+	tests_scenarios := []ifMatchTestCase{
+		{testName: "Strong Tag", header: `"3"`, versions: []int64{3}},
+		{testName: "Tag List", header: `"1", "2"`, versions: []int64{1, 2}},
+		{testName: "Usable Tag In List", header: `W/"1", "abc", "2"`, versions: []int64{2}},
+		{testName: "Any Version", header: "*"},
+		{testName: "Missing Header", header: "", expected: errPreconditionRequired},
+		{testName: "Weak Tag", header: `W/"3"`, expected: errETagMismatch},
+		{testName: "Foreign Tag", header: `"abc"`, expected: errETagMismatch},
+		{testName: "Unquoted Tag", header: "3", expected: errETagMismatch},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/users/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+
+			versions, err := ifMatchVersions(r)
+			assert.ErrorIs(t, err, tt.expected)
+			assert.Equal(t, tt.versions, versions)
+		})
+	}
+}
+
+type conditionalGetTestCase struct {
+	testName    string
+	etag        string
+	ifNoneMatch string
+	expected    int
+}
+
+func TestWriteConditionalJSON(t *testing.T) {
+	payload := []byte(`{"id":"1"}`)
+	tests_scenarios := []conditionalGetTestCase{
+		{testName: "No Validator", etag: `"1"`, expected: http.StatusOK},
+		{testName: "Strong Match", etag: `"1"`, ifNoneMatch: `"1"`, expected: http.StatusNotModified},
+		{testName: "Stale Copy", etag: `"2"`, ifNoneMatch: `"1"`, expected: http.StatusOK},
+		{testName: "Match In List", etag: `"2"`, ifNoneMatch: `"1", "2"`, expected: http.StatusNotModified},
+		{
+			testName: "Weak Match", etag: weakETag(payload), ifNoneMatch: weakETag(payload),
+			expected: http.StatusNotModified,
+		},
+		{testName: "Wildcard", etag: `"1"`, ifNoneMatch: "*", expected: http.StatusNotModified},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+
+			writeConditionalJSON(w, r, tt.etag, payload)
+
+			assert.Equal(t, tt.expected, w.Code)
+			assert.Equal(t, tt.etag, w.Header().Get("ETag"), "should always send the validator")
+			assert.Equal(t, cacheControl, w.Header().Get("Cache-Control"))
+			if tt.expected == http.StatusNotModified {
+				assert.Empty(t, w.Body.String(), "should not send a body on 304")
+			} else {
+				assert.JSONEq(t, string(payload), w.Body.String())
+			}
+		})
+	}
+}
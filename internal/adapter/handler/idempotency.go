@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"bytes"
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+	"clean-go-rest-api/internal/usecase"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyRetryAfterSecs = 1
+)
+
+// maxIdempotentBodyBytes bounds the bodies read up front to fingerprint
+// requests carrying an idempotency key.
+const maxIdempotentBodyBytes = 1 << 20
+
+// idempotent lets clients safely retry next by sending an Idempotency-Key
+// header: the first response to a key is stored and replayed verbatim to the
+// retries, instead of running next again. Requests without the header are
+// served as usual.
+//
+// Server errors are not stored, nor are panics, so a retry runs next again.
+func idempotent(useCase usecase.IIdempotencyUseCase, logger logger.ILogger, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || useCase == nil {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeProblem(w, r, status, err.Error())
+			logger.Error("Error reading request body: " + err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// The query string is part of the request, so it takes part in the
+		// fingerprint along with the body.
+		scope := r.Method + " " + r.URL.Path
+		request := append([]byte(r.URL.RawQuery+"\n"), body...)
+		replay, err := useCase.Begin(r.Context(), scope, key, request)
+		if err != nil {
+			if errors.Is(err, usecase.ErrIdempotencyKeyInProgress) {
+				w.Header().Set("Retry-After", strconv.Itoa(idempotencyRetryAfterSecs))
+			}
+			writeError(w, r, err)
+			logger.Error("Error checking idempotency key: " + err.Error())
+			return
+		}
+		if replay != nil {
+			writeIdempotentResponse(w, *replay)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		completed := false
+		// Stored responses must outlive the client, which may well have gone
+		// away by now: that is why it retries.
+		ctx := context.WithoutCancel(r.Context())
+		defer func() {
+			if completed {
+				return
+			}
+			if err := useCase.Release(ctx, scope, key); err != nil {
+				logger.Error("Error releasing idempotency key: " + err.Error())
+			}
+		}()
+
+		next(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		response := entity.IdempotentResponse{
+			StatusCode: status,
+			Header:     storedHeader(w.Header()),
+			Body:       recorder.body.Bytes(),
+		}
+		if err := useCase.Complete(ctx, scope, key, response); err != nil {
+			logger.Error("Error storing idempotent response: " + err.Error())
+			return
+		}
+		completed = true
+	}
+}
+
+// storedHeader keeps the response headers worth replaying. The request id
+// belongs to the request being answered, not to the stored response.
+func storedHeader(header http.Header) map[string][]string {
+	stored := header.Clone()
+	stored.Del(requestIDHeader)
+	return stored
+}
+
+func writeIdempotentResponse(w http.ResponseWriter, response entity.IdempotentResponse) {
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(response.StatusCode)
+	w.Write(response.Body)
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
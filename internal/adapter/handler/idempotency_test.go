@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/usecase"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotent(t *testing.T) {
+	calls := 0
+	status := http.StatusCreated
+	next := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call":%d,"body":%s}`, calls, body)
+	}
+	useCase := usecase.NewIdempotencyUseCase(usecase.SetupMockIdempotencyRepo(), time.Hour, time.Minute)
+	handler := idempotent(useCase, logger.NewLogger(), next)
+
+	serve := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		if key != "" {
+			r.Header.Set(idempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	first := serve("abc", `{"name":"John"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(idempotentReplayedHeader), "first response is not a replay")
+
+	retry := serve("abc", `{"name":"John"}`)
+	assert.Equal(t, 1, calls, "a retry must not run the handler again")
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String(), "a retry replays the first body")
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, "true", retry.Header().Get(idempotentReplayedHeader))
+
+	reused := serve("abc", `{"name":"Jane"}`)
+	assert.Equal(t, http.StatusConflict, reused.Code, "a key cannot be reused for another body")
+
+	serve("", `{"name":"John"}`)
+	assert.Equal(t, 2, calls, "requests without a key always run")
+
+	status = http.StatusInternalServerError
+	serve("def", `{"name":"John"}`)
+	status = http.StatusCreated
+	recovered := serve("def", `{"name":"John"}`)
+	assert.Equal(t, 4, calls, "server errors are not replayed")
+	assert.Equal(t, http.StatusCreated, recovered.Code)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// negotiate picks the offered media type the client prefers according to
+// its Accept header, the first offer when it sent none, or "" when it
+// accepts none of them. Each offer takes the quality of the most specific
+// range matching it, as RFC 9110 prescribes.
+func negotiate(r *http.Request, offers ...string) string {
+	header := r.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	best, bestQuality := "", 0.0
+	for _, offer := range offers {
+		quality, specificity := 0.0, -1
+		for _, accepted := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+			if err != nil {
+				continue
+			}
+			if s := mediaRangeSpecificity(mediaType, offer); s > specificity {
+				specificity, quality = s, acceptQuality(params["q"])
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best
+}
+
+// mediaRangeSpecificity ranks how closely mediaRange matches mediaType:
+// 2 for the type itself, 1 for type/*, 0 for */* and -1 for no match.
+func mediaRangeSpecificity(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") &&
+		strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	}
+	return -1
+}
+
+func acceptQuality(q string) float64 {
+	if q == "" {
+		return 1
+	}
+	quality, err := strconv.ParseFloat(q, 64)
+	if err != nil || quality < 0 || quality > 1 {
+		return 0
+	}
+	return quality
+}
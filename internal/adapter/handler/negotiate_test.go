@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type negotiateTestCase struct {
+	testName string
+	accept   string
+	expected string
+}
+
+func TestNegotiate(t *testing.T) {
+	tests_scenarios := []negotiateTestCase{
+		{testName: "No Accept", accept: "", expected: csvContentType},
+		{testName: "Anything", accept: "*/*", expected: csvContentType},
+		{testName: "Exact Type", accept: "application/x-ndjson", expected: ndjsonContentType},
+		{testName: "Quality", accept: "text/csv;q=0.5, application/x-ndjson", expected: ndjsonContentType},
+		{testName: "Specific Range Wins", accept: "text/*;q=0, */*;q=0.1", expected: ndjsonContentType},
+		{testName: "Nothing Acceptable", accept: "application/xml", expected: ""},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users/export", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			assert.Equal(t, tt.expected, negotiate(r, csvContentType, ndjsonContentType))
+		})
+	}
+}
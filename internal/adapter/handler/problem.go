@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"clean-go-rest-api/internal/domain/domainerr"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/usecase"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// problemTypes maps a status code to the problem type URI reported to
+// clients. Statuses without a dedicated entry are reported as about:blank,
+// as RFC 7807 recommends when the status code alone carries the semantics.
+var problemTypes = map[int]string{
+	http.StatusBadRequest:          "/problems/bad-request",
+	http.StatusNotFound:            "/problems/not-found",
+	http.StatusConflict:            "/problems/conflict",
+	http.StatusPreconditionFailed:  "/problems/precondition-failed",
+	http.StatusUnprocessableEntity: "/problems/validation-error",
+	http.StatusInternalServerError: "/problems/internal-error",
+	http.StatusServiceUnavailable:  "/problems/service-unavailable",
+}
+
+// statusFromError translates domain errors into HTTP status codes. Anything
+// that is not a known domain error is reported as 500.
+func statusFromError(err error) int {
+	switch {
+	case errors.Is(err, domainerr.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domainerr.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, domainerr.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domainerr.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, domainerr.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, usecase.ErrBatchAborted):
+		return http.StatusFailedDependency
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func newProblem(r *http.Request, status int, detail string) dto.ProblemDetails {
+	problemType, ok := problemTypes[status]
+	if !ok {
+		problemType = "about:blank"
+	}
+	return dto.ProblemDetails{
+		Type:      problemType,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: RequestIDFromContext(r.Context()),
+	}
+}
+
+func writeProblemDetails(w http.ResponseWriter, problem dto.ProblemDetails) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblemDetails(w, newProblem(r, status, detail))
+}
+
+// writeError answers with the problem matching err. Internal errors are not
+// echoed back to the client; callers are expected to log them.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblemDetails(w, problemFromError(r, err))
+}
+
+func problemFromError(r *http.Request, err error) dto.ProblemDetails {
+	status := statusFromError(err)
+	detail := err.Error()
+	if status == http.StatusInternalServerError {
+		detail = "an unexpected error occurred"
+	}
+
+	problem := newProblem(r, status, detail)
+	var domainErr *domainerr.Error
+	if errors.As(err, &domainErr) {
+		for _, field := range domainErr.Fields {
+			problem.Errors = append(problem.Errors, dto.FieldError{
+				Field:   field.Field,
+				Message: field.Message,
+			})
+		}
+	}
+	return problem
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"clean-go-rest-api/internal/domain/domainerr"
+	"clean-go-rest-api/internal/domain/dto"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// queryBool reads an optional boolean query parameter, defaulting to false.
+func queryBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, domainerr.Validation(
+			"invalid query parameter",
+			domainerr.FieldError{Field: name, Message: "must be true or false"},
+		)
+	}
+	return parsed, nil
+}
+
+// nextPageURL is the request URL with its cursor replaced, keeping every
+// other parameter so the next page is searched the same way.
+func nextPageURL(r *http.Request, cursor string) string {
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return next.String()
+}
+
+// searchUsersRequest reads the user search parameters shared by searches
+// and exports.
+func searchUsersRequest(r *http.Request) (dto.SearchUsersRequest, error) {
+	includeDeleted, err := queryBool(r, "include_deleted")
+	if err != nil {
+		return dto.SearchUsersRequest{}, err
+	}
+
+	query := r.URL.Query()
+	return dto.SearchUsersRequest{
+		Mode:           query.Get("mode"),
+		Query:          query.Get("q"),
+		Name:           query.Get("name"),
+		Email:          query.Get("email"),
+		EmailDomain:    query.Get("email_domain"),
+		CreatedAfter:   query.Get("created_after"),
+		CreatedBefore:  query.Get("created_before"),
+		UpdatedAfter:   query.Get("updated_after"),
+		UpdatedBefore:  query.Get("updated_before"),
+		IncludeDeleted: includeDeleted,
+		Sort:           query.Get("sort"),
+		Limit:          query.Get("limit"),
+		Cursor:         query.Get("cursor"),
+	}, nil
+}
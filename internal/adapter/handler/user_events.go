@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/domain/entity"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+
+	// eventStreamHeartbeat is how often an idle stream gets a comment, well
+	// within the idle timeouts of common proxies.
+	eventStreamHeartbeat = 15 * time.Second
+)
+
+// Events streams user changes as Server-Sent Events, each with the ID of its
+// event so that EventSource clients resume after it through Last-Event-ID
+// when they reconnect. The stream ends when the client goes away, when the
+// server shuts down, or when the client cannot keep up; clients are then
+// expected to reconnect.
+func (h *UserHandler) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, "streaming is not supported")
+		h.logger.Error("Error: response writer does not support flushing")
+		return
+	}
+
+	var lastEventID uuid.UUID
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := uuid.Parse(header)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "invalid Last-Event-ID")
+			h.logger.Error("Error parsing Last-Event-ID: " + err.Error())
+			return
+		}
+		lastEventID = id
+	}
+
+	replay, events, cancel := h.events.Subscribe(lastEventID)
+	defer cancel()
+
+	h.logger.Info(fmt.Sprintf("Streaming user events, replaying %d", len(replay)))
+	w.Header().Set("Content-Type", eventStreamContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	// Keeps buffering proxies such as nginx from holding events back.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for _, event := range replay {
+		if err := h.writeEvent(w, event); err != nil {
+			h.logger.Error("Error writing user event: " + err.Error())
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			h.logger.Info("User event stream closed by the client")
+			return
+		case event, ok := <-events:
+			if !ok {
+				h.logger.Info("User event stream ended by the server")
+				return
+			}
+			if err := h.writeEvent(w, event); err != nil {
+				h.logger.Error("Error writing user event: " + err.Error())
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				h.logger.Error("Error writing heartbeat: " + err.Error())
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes event in the text/event-stream format. Its JSON holds
+// no newline, so it fits on a single data line.
+func (h *UserHandler) writeEvent(w io.Writer, event entity.OutboxEvent) error {
+	data, err := json.Marshal(dto.NewUserEventResponse(event, h.location))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
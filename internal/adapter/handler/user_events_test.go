@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/domain/entity"
+	"clean-go-rest-api/internal/usecase"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// readEventStream returns the next event of stream, or the next comment,
+// without its trailing blank line.
+func readEventStream(t *testing.T, stream *bufio.Reader) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestUserHandler_Events(t *testing.T) {
+	feed := usecase.NewUserEventFeed(usecase.SetupMockOutboxRepo(), logger.NewLogger(), usecase.UserEventReplaySize)
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	first := entity.NewUserEvent(entity.UserCreated, nil, &user)
+	second := entity.NewUserEvent(entity.UserUpdated, &user, &user)
+	feed.Publish(first)
+	feed.Publish(second)
+
+	h := &UserHandler{events: feed, logger: logger.NewLogger(), location: time.UTC, heartbeat: 10 * time.Millisecond}
+	server := httptest.NewServer(http.HandlerFunc(h.Events))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Last-Event-ID", first.ID.String())
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, eventStreamContentType, resp.Header.Get("Content-Type"))
+	stream := bufio.NewReader(resp.Body)
+
+	replayed := readEventStream(t, stream)
+	assert.Equal(t, "id: "+second.ID.String(), replayed[0], "should replay the events after Last-Event-ID")
+	assert.Equal(t, "event: user.updated", replayed[1])
+	assert.Contains(t, replayed[2], `"user_id":"`+user.ID.String()+`"`)
+
+	third := entity.NewUserEvent(entity.UserDeleted, &user, nil)
+	feed.Publish(third)
+	var live []string
+	for live == nil || strings.HasPrefix(live[0], ":") {
+		live = readEventStream(t, stream)
+	}
+	assert.Equal(t, []string{"id: " + third.ID.String(), "event: user.deleted"}, live[:2], "should stream new events")
+
+	assert.Equal(t, []string{": heartbeat"}, readEventStream(t, stream), "should send heartbeats while idle")
+
+	feed.Close()
+	_, err = stream.ReadString('\n')
+	for err == nil {
+		_, err = stream.ReadString('\n')
+	}
+	assert.ErrorContains(t, err, "EOF", "should end the stream when the feed closes")
+}
+
+// announcingPublisher hands the events it publishes to changes, as they
+// reach every instance through the database.
+type announcingPublisher struct {
+	changes chan entity.UserChange
+}
+
+func (p *announcingPublisher) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	p.changes <- entity.UserChange{Type: entity.UserEventPublished, UserID: event.AggregateID, Event: &event}
+	return nil
+}
+
+// TestUserHandler_EventsFromOutbox follows a change from the outbox, through
+// the dispatcher announcing it and the feed following the announcements, to
+// a client of the event stream.
+func TestUserHandler_EventsFromOutbox(t *testing.T) {
+	outbox := usecase.SetupMockOutboxRepo()
+	feed := usecase.NewUserEventFeed(outbox, logger.NewLogger(), usecase.UserEventReplaySize)
+	defer feed.Close()
+	changes := make(chan entity.UserChange)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go feed.Follow(ctx, changes)
+	// Once the feed takes a change, it is done with its initial resync,
+	// which would end the stream.
+	changes <- entity.UserChange{Type: entity.UserCreated, UserID: uuid.New(), Version: 1}
+
+	useCase := usecase.NewUserUseCase(usecase.SetupMockRepo(), usecase.SetupMockUnitOfWork(), outbox)
+	dispatcher := usecase.NewOutboxDispatcher(
+		usecase.SetupMockUnitOfWork(), outbox, &announcingPublisher{changes: changes}, logger.NewLogger(), time.Hour, 3,
+	)
+
+	h := &UserHandler{events: feed, logger: logger.NewLogger(), location: time.UTC, heartbeat: time.Hour}
+	server := httptest.NewServer(http.HandlerFunc(h.Events))
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	stream := bufio.NewReader(resp.Body)
+
+	id, err := useCase.Add(context.Background(), dto.CreateUserRequest{Name: "John Doe", Email: "john@example.com"})
+	assert.NoError(t, err)
+	dispatched, err := dispatcher.DispatchOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dispatched)
+
+	event := readEventStream(t, stream)
+	assert.Equal(t, "event: user.created", event[1], "should stream the dispatched event")
+	assert.Contains(t, event[2], `"user_id":"`+id.String()+`"`)
+
+	changes <- entity.UserChange{Type: entity.UserChangesMissed}
+	_, err = stream.ReadString('\n')
+	for err == nil {
+		_, err = stream.ReadString('\n')
+	}
+	assert.ErrorContains(t, err, "EOF", "should end the stream for the client to resume once changes were missed")
+}
+
+func TestUserHandler_EventsInvalidLastEventID(t *testing.T) {
+	h := &UserHandler{
+		events:   usecase.NewUserEventFeed(usecase.SetupMockOutboxRepo(), logger.NewLogger(), 1),
+		logger:   logger.NewLogger(),
+		location: time.UTC,
+	}
+	r := httptest.NewRequest(http.MethodGet, "/users/events", nil)
+	r.Header.Set("Last-Event-ID", "42")
+	w := httptest.NewRecorder()
+
+	h.Events(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"bufio"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/domain/entity"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	csvContentType    = "text/csv"
+	ndjsonContentType = "application/x-ndjson"
+
+	// exportFlushInterval is the number of users written between flushes,
+	// so clients see progress without paying a flush per row.
+	exportFlushInterval = 1000
+)
+
+var userExportColumns = []string{"id", "name", "email", "version", "created_at", "updated_at", "deleted_at"}
+
+// userExportWriter encodes users one at a time in an export format.
+type userExportWriter interface {
+	writeHeader() error
+	writeUser(user dto.UserResponse) error
+	flush() error
+}
+
+type csvUserWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvUserWriter) writeHeader() error {
+	return c.w.Write(userExportColumns)
+}
+
+func (c *csvUserWriter) writeUser(user dto.UserResponse) error {
+	var deletedAt string
+	if user.DeletedAt != nil {
+		deletedAt = user.DeletedAt.Format(time.RFC3339Nano)
+	}
+	return c.w.Write([]string{
+		user.ID.String(),
+		csvCell(user.Name),
+		csvCell(user.Email),
+		strconv.FormatInt(user.Version, 10),
+		user.CreatedAt.Format(time.RFC3339Nano),
+		user.UpdatedAt.Format(time.RFC3339Nano),
+		deletedAt,
+	})
+}
+
+// csvCell keeps spreadsheets from evaluating a user supplied value as a
+// formula, by prefixing a quote to values starting like one.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (c *csvUserWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonUserWriter writes one JSON object per line, as GetById renders them.
+type ndjsonUserWriter struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (n *ndjsonUserWriter) writeHeader() error {
+	return nil
+}
+
+func (n *ndjsonUserWriter) writeUser(user dto.UserResponse) error {
+	return n.encoder.Encode(user)
+}
+
+func (n *ndjsonUserWriter) flush() error {
+	return n.buffer.Flush()
+}
+
+// Export streams every user matching the search parameters, in the format
+// picked by Accept. Users are written as they are read from the database, so
+// the response starts right away and memory stays flat. Once it has started,
+// a failure can only be signaled by cutting the response short.
+func (h *UserHandler) Export(w http.ResponseWriter, r *http.Request) {
+	contentType := negotiate(r, csvContentType, ndjsonContentType)
+	if contentType == "" {
+		writeProblem(w, r, http.StatusNotAcceptable,
+			fmt.Sprintf("exports are available as %s or %s", csvContentType, ndjsonContentType),
+		)
+		h.logger.Error("Error: no acceptable export format in " + r.Header.Get("Accept"))
+		return
+	}
+
+	req, err := searchUsersRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error parsing query: " + err.Error())
+		return
+	}
+
+	var writer userExportWriter
+	extension := "csv"
+	if contentType == ndjsonContentType {
+		buffer := bufio.NewWriter(w)
+		writer = &ndjsonUserWriter{buffer: buffer, encoder: json.NewEncoder(buffer)}
+		extension = "ndjson"
+	} else {
+		writer = &csvUserWriter{w: csv.NewWriter(w)}
+	}
+	flusher, _ := w.(http.Flusher)
+
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%s"`, extension))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Vary", "Accept")
+		w.WriteHeader(http.StatusOK)
+		return writer.writeHeader()
+	}
+
+	h.logger.Info(fmt.Sprintf("Received request to export users as %s: %s", contentType, r.URL.RawQuery))
+	exported := 0
+	err = h.useCase.Export(r.Context(), req, func(user entity.User) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := writer.writeUser(dto.NewUserResponse(user, h.location)); err != nil {
+			return err
+		}
+		exported++
+		if exported%exportFlushInterval != 0 {
+			return nil
+		}
+		if err := writer.flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = writer.flush()
+	}
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("Error exporting users after %d rows: %s", exported, err.Error()))
+		if !started {
+			writeError(w, r, err)
+			return
+		}
+		// Aborting the response tells the client the export is incomplete,
+		// instead of ending it as if every user had been written.
+		panic(http.ErrAbortHandler)
+	}
+
+	h.logger.Info(fmt.Sprintf("Exported %d users", exported))
+}
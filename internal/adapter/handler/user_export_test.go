@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/domain/dto"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type csvUserWriterTestCase struct {
+	testName      string
+	name          string
+	expectedName  string
+	email         string
+	expectedEmail string
+}
+
+func TestCSVUserWriter_WriteUser(t *testing.T) {
+	tests_scenarios := []csvUserWriterTestCase{
+		{
+			testName:      "Plain Values",
+			name:          "John Doe",
+			expectedName:  "John Doe",
+			email:         "john@example.com",
+			expectedEmail: "john@example.com",
+		},
+		{
+			testName:      "Formula",
+			name:          "=HYPERLINK(\"x\")",
+			expectedName:  "'=HYPERLINK(\"x\")",
+			email:         "a@example.com",
+			expectedEmail: "a@example.com",
+		},
+		{
+			testName:      "Plus",
+			name:          "+1+1",
+			expectedName:  "'+1+1",
+			email:         "+a@example.com",
+			expectedEmail: "'+a@example.com",
+		},
+		{
+			testName:      "Minus",
+			name:          "-2+3",
+			expectedName:  "'-2+3",
+			email:         "a@example.com",
+			expectedEmail: "a@example.com",
+		},
+		{
+			testName:      "At Sign",
+			name:          "@SUM(A1)",
+			expectedName:  "'@SUM(A1)",
+			email:         "a@example.com",
+			expectedEmail: "a@example.com",
+		},
+		{
+			testName:      "Tab",
+			name:          "\t=1",
+			expectedName:  "'\t=1",
+			email:         "a@example.com",
+			expectedEmail: "a@example.com",
+		},
+		{
+			testName:      "Carriage Return",
+			name:          "\r=1",
+			expectedName:  "'\r=1",
+			email:         "a@example.com",
+			expectedEmail: "a@example.com",
+		},
+		{
+			testName:      "Sign Inside",
+			name:          "Mary-Jane",
+			expectedName:  "Mary-Jane",
+			email:         "mary+jane@example.com",
+			expectedEmail: "mary+jane@example.com",
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			var buffer bytes.Buffer
+			writer := &csvUserWriter{w: csv.NewWriter(&buffer)}
+			user := dto.UserResponse{ID: uuid.New(), Name: tt.name, Email: tt.email, Version: 1, CreatedAt: time.Now()}
+			assert.NoError(t, writer.writeUser(user))
+			assert.NoError(t, writer.flush())
+
+			record, err := csv.NewReader(&buffer).Read()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedName, record[1])
+			assert.Equal(t, tt.expectedEmail, record[2])
+		})
+	}
+}
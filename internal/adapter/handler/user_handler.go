@@ -4,29 +4,60 @@ package handler
 
 import (
 	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/domainerr"
 	"clean-go-rest-api/internal/domain/dto"
 	"clean-go-rest-api/internal/usecase"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"sort"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 type UserHandler struct {
-	useCase usecase.IUserUseCase
-	logger  logger.ILogger
+	useCase     usecase.IUserUseCase
+	idempotency usecase.IIdempotencyUseCase
+	events      usecase.IUserEventFeed
+	logger      logger.ILogger
+	location    *time.Location
+	heartbeat   time.Duration
 }
 
-func NewUserHandler(UseCase usecase.IUserUseCase, Logger logger.ILogger) *UserHandler {
-	return &UserHandler{useCase: UseCase, logger: logger.NewLogger()}
+// NewUserHandler renders user timestamps in location. User creation honours
+// Idempotency-Key headers through idempotency, and changes are streamed
+// from events.
+func NewUserHandler(
+	UseCase usecase.IUserUseCase, idempotency usecase.IIdempotencyUseCase, events usecase.IUserEventFeed,
+	Logger logger.ILogger, location *time.Location,
+) *UserHandler {
+	return &UserHandler{
+		useCase:     UseCase,
+		idempotency: idempotency,
+		events:      events,
+		logger:      Logger,
+		location:    location,
+		heartbeat:   eventStreamHeartbeat,
+	}
 }
 
 func (h *UserHandler) RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/users", h.Add).Methods(http.MethodPost)
+	r.HandleFunc("/users", idempotent(h.idempotency, h.logger, h.Add)).Methods(http.MethodPost)
+	r.HandleFunc("/users/batch", h.AddBatch).Methods(http.MethodPost)
+	r.HandleFunc("/users/import", h.Import).Methods(http.MethodPost)
+	// Registered ahead of /users/{id}, which would otherwise take "suggest",
+	// "export" and "events" for IDs.
+	r.HandleFunc("/users/suggest", h.Suggest).Methods(http.MethodGet)
+	r.HandleFunc("/users/export", h.Export).Methods(http.MethodGet)
+	r.HandleFunc("/users/events", h.Events).Methods(http.MethodGet)
 	r.HandleFunc("/users/{id}", h.Delete).Methods(http.MethodDelete)
 	r.HandleFunc("/users/{id}", h.Update).Methods(http.MethodPut)
+	r.HandleFunc("/users/{id}", h.Patch).Methods(http.MethodPatch)
+	r.HandleFunc("/users/{id}/restore", h.Restore).Methods(http.MethodPost)
 	r.HandleFunc("/users/{id}", h.GetById).Methods(http.MethodGet)
 	r.HandleFunc("/users", h.Search).Methods(http.MethodGet)
 }
@@ -34,17 +65,15 @@ func (h *UserHandler) RegisterRoutes(r *mux.Router) {
 func (h *UserHandler) Add(w http.ResponseWriter, r *http.Request) {
 	var req dto.CreateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: err.Error()})
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
 		h.logger.Error("Error decoding request body: " + err.Error())
 		return
 	}
 
 	h.logger.Info("Received request to create user")
-	id, err := h.useCase.Add(req)
+	id, err := h.useCase.Add(r.Context(), req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: err.Error()})
+		writeError(w, r, err)
 		h.logger.Error("Error creating user: " + err.Error())
 		return
 	}
@@ -54,21 +83,140 @@ func (h *UserHandler) Add(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(dto.CreateUserResponse{ID: id})
 }
 
+// maxBatchBodyBytes leaves ample room for usecase.MaxBatchSize users.
+const maxBatchBodyBytes = 2 << 20
+
+// AddBatch creates an array of users. By default the batch is all or
+// nothing; with ?mode=best_effort every valid user is created regardless of
+// the others. Each item gets its own status in the response.
+func (h *UserHandler) AddBatch(w http.ResponseWriter, r *http.Request) {
+	var bestEffort bool
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "atomic":
+	case "best_effort":
+		bestEffort = true
+	default:
+		writeError(w, r, domainerr.Validation(
+			"invalid query parameter",
+			domainerr.FieldError{Field: "mode", Message: "must be atomic or best_effort"},
+		))
+		h.logger.Error("Error: unknown batch mode " + mode)
+		return
+	}
+
+	var users []dto.CreateUserRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&users); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeProblem(w, r, status, err.Error())
+		h.logger.Error("Error decoding request body: " + err.Error())
+		return
+	}
+
+	h.logger.Info(fmt.Sprintf("Received request to create %d users", len(users)))
+	results, err := h.useCase.AddBatch(r.Context(), dto.CreateUsersRequest{Users: users, BestEffort: bestEffort})
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error creating users: " + err.Error())
+		return
+	}
+
+	response := dto.CreateUsersResponse{Results: make([]dto.BatchItemResponse, 0, len(results))}
+	created := 0
+	// A partial batch answers with 207 Multi-Status, an aborted one with the
+	// status of its first rejected user.
+	status := http.StatusCreated
+	for i, result := range results {
+		item := dto.BatchItemResponse{Index: i, Status: http.StatusCreated}
+		if result.Err != nil {
+			problem := problemFromError(r, result.Err)
+			item.Status, item.Error = problem.Status, &problem
+			switch {
+			case bestEffort:
+				status = http.StatusMultiStatus
+			case status == http.StatusCreated && !errors.Is(result.Err, usecase.ErrBatchAborted):
+				status = problem.Status
+			}
+		} else {
+			id := result.ID
+			item.ID = &id
+			created++
+		}
+		response.Results = append(response.Results, item)
+	}
+
+	h.logger.Info(fmt.Sprintf("Created %d of %d users", created, len(results)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// maxImportBodyBytes bounds CSV imports, which are streamed rather than
+// buffered.
+const maxImportBodyBytes = 256 << 20
+
+// Import creates users from a text/csv body. With ?dry_run=true it only
+// reports what would be created and rejected.
+func (h *UserHandler) Import(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/csv" {
+		writeProblem(w, r, http.StatusUnsupportedMediaType, "content type must be text/csv")
+		h.logger.Error("Error: unsupported import content type: " + r.Header.Get("Content-Type"))
+		return
+	}
+
+	dryRun, err := queryBool(r, "dry_run")
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error parsing query: " + err.Error())
+		return
+	}
+
+	h.logger.Info(fmt.Sprintf("Received request to import users (dry run: %t)", dryRun))
+	report, err := h.useCase.Import(r.Context(), dto.ImportUsersRequest{
+		CSV:    http.MaxBytesReader(w, r.Body, maxImportBodyBytes),
+		DryRun: dryRun,
+	})
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, err.Error())
+		} else {
+			writeError(w, r, err)
+		}
+		h.logger.Error("Error importing users: " + err.Error())
+		return
+	}
+
+	h.logger.Info(fmt.Sprintf("Imported %d users, rejected %d", report.Created, len(report.Rejected)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
 func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: "invalid ID format"})
+		writeProblem(w, r, http.StatusBadRequest, "invalid ID format")
 		h.logger.Error("Error parsing ID: " + err.Error())
 		return
 	}
 
+	versions, err := ifMatchVersions(r)
+	if err != nil {
+		writeIfMatchError(w, r, err)
+		h.logger.Error("Error checking If-Match: " + err.Error())
+		return
+	}
+
 	h.logger.Info(fmt.Sprintf("Received request to delete user with ID: %s", id))
-	err = h.useCase.Delete(dto.DeleteUserRequest{ID: id})
+	err = h.useCase.Delete(r.Context(), dto.DeleteUserRequest{ID: id, Versions: versions})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: err.Error()})
-		h.logger.Error("Error creating user: " + err.Error())
+		writeError(w, r, err)
+		h.logger.Error("Error deleting user: " + err.Error())
 		return
 	}
 
@@ -79,81 +227,271 @@ func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: "invalid ID format"})
+		writeProblem(w, r, http.StatusBadRequest, "invalid ID format")
 		h.logger.Error("Error parsing ID: " + err.Error())
 		return
 	}
 
+	versions, err := ifMatchVersions(r)
+	if err != nil {
+		writeIfMatchError(w, r, err)
+		h.logger.Error("Error checking If-Match: " + err.Error())
+		return
+	}
+
 	h.logger.Info(fmt.Sprintf("Received request to update user with ID: %s", id))
 	var req dto.UpdateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: err.Error()})
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
 		h.logger.Error("Error decoding request body: " + err.Error())
 		return
 	}
 	req.ID = id
-	err = h.useCase.Update(req)
+	req.Versions = versions
+	user, err := h.useCase.Update(r.Context(), req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: err.Error()})
+		writeError(w, r, err)
 		h.logger.Error("Error updating user: " + err.Error())
 		return
 	}
 
 	h.logger.Info(fmt.Sprintf("User with ID %s updated successfully", id))
+	w.Header().Set("ETag", userETag(user.Version))
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user, h.location))
+}
+
+func (h *UserHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid ID format")
+		h.logger.Error("Error parsing ID: " + err.Error())
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != mergePatchContentType {
+		writeProblem(w, r, http.StatusUnsupportedMediaType,
+			"content type must be "+mergePatchContentType,
+		)
+		h.logger.Error("Error: unsupported patch content type: " + r.Header.Get("Content-Type"))
+		return
+	}
+
+	versions, err := ifMatchVersions(r)
+	if err != nil {
+		writeIfMatchError(w, r, err)
+		h.logger.Error("Error checking If-Match: " + err.Error())
+		return
+	}
+
+	h.logger.Info(fmt.Sprintf("Received request to patch user with ID: %s", id))
+	// A null patch decodes without error into a nil map, but would replace
+	// the whole user with null.
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		writeProblem(w, r, http.StatusBadRequest, "merge patch must be a JSON object")
+		if err != nil {
+			h.logger.Error("Error decoding request body: " + err.Error())
+		} else {
+			h.logger.Error("Error: merge patch is null")
+		}
+		return
+	}
+
+	req, err := userPatchFromMergePatch(patch)
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error reading merge patch: " + err.Error())
+		return
+	}
+	req.ID = id
+	req.Versions = versions
+
+	user, err := h.useCase.Patch(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error patching user: " + err.Error())
+		return
+	}
+
+	h.logger.Info(fmt.Sprintf("User with ID %s patched successfully", id))
+	w.Header().Set("ETag", userETag(user.Version))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user, h.location))
+}
+
+// Restore brings back a deleted user. If-Match is honoured when sent but,
+// unlike the other writes, not required.
+func (h *UserHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid ID format")
+		h.logger.Error("Error parsing ID: " + err.Error())
+		return
+	}
+
+	var versions []int64
+	if r.Header.Get("If-Match") != "" {
+		versions, err = ifMatchVersions(r)
+		if err != nil {
+			writeIfMatchError(w, r, err)
+			h.logger.Error("Error checking If-Match: " + err.Error())
+			return
+		}
+	}
+
+	h.logger.Info(fmt.Sprintf("Received request to restore user with ID: %s", id))
+	user, err := h.useCase.Restore(r.Context(), dto.RestoreUserRequest{ID: id, Versions: versions})
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error restoring user: " + err.Error())
+		return
+	}
+
+	h.logger.Info(fmt.Sprintf("User with ID %s restored successfully", id))
+	w.Header().Set("ETag", userETag(user.Version))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user, h.location))
 }
 
 func (h *UserHandler) GetById(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: "invalid ID format"})
+		writeProblem(w, r, http.StatusBadRequest, "invalid ID format")
 		h.logger.Error("Error parsing ID: " + err.Error())
 		return
 	}
 
+	includeDeleted, err := queryBool(r, "include_deleted")
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error parsing query: " + err.Error())
+		return
+	}
+
 	h.logger.Info(fmt.Sprintf("Received request to get user with ID: %s", id))
-	user, err := h.useCase.GetById(id)
+	getById := h.useCase.GetById
+	if includeDeleted {
+		getById = h.useCase.GetByIdIncludingDeleted
+	}
+	user, err := getById(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: err.Error()})
+		writeError(w, r, err)
 		h.logger.Error("Error getting user: " + err.Error())
 		return
 	}
-	if user.ID == uuid.Nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: "user not found"})
-		h.logger.Error(fmt.Sprintf("User with ID %s not found", id))
+
+	payload, err := json.Marshal(dto.NewUserResponse(user, h.location))
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error encoding user: " + err.Error())
 		return
 	}
 
 	h.logger.Info(fmt.Sprintf("User with ID %s retrieved successfully", id))
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(user)
+	writeConditionalJSON(w, r, userETag(user.Version), payload)
 }
 
 func (h *UserHandler) Search(w http.ResponseWriter, r *http.Request) {
-	name := r.URL.Query().Get("name")
-	if name == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: "name parameter is required"})
-		h.logger.Error("Error: name parameter is required")
+	req, err := searchUsersRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error parsing query: " + err.Error())
 		return
 	}
 
-	h.logger.Info(fmt.Sprintf("Received request to search users with name: %s", name))
-	users, err := h.useCase.Search(name)
+	h.logger.Info(fmt.Sprintf("Received request to search users: %s", r.URL.RawQuery))
+	page, err := h.useCase.Search(r.Context(), req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(dto.ErrorResponse{Reason: err.Error()})
+		writeError(w, r, err)
 		h.logger.Error("Error searching users: " + err.Error())
 		return
 	}
 
-	h.logger.Info(fmt.Sprintf("Found %d users with name: %s", len(users), name))
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(users)
+	response := dto.NewUserPageResponse(page, h.location)
+	if page.Next != nil {
+		response.Next = nextPageURL(r, page.Next.Encode())
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, response.Next))
+	}
+
+	payload, err := json.Marshal(response)
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error encoding users: " + err.Error())
+		return
+	}
+
+	h.logger.Info(fmt.Sprintf("Found %d users", len(page.Users)))
+	writeConditionalJSON(w, r, weakETag(payload), payload)
+}
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// userPatchFromMergePatch reads an RFC 7396 merge patch for a user. Name and
+// email are required on the resource, so removing them with null is
+// rejected, and so is any member the resource does not have.
+func userPatchFromMergePatch(patch map[string]json.RawMessage) (dto.PatchUserRequest, error) {
+	var req dto.PatchUserRequest
+	var fields []domainerr.FieldError
+	members := make([]string, 0, len(patch))
+	for member := range patch {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+
+	for _, member := range members {
+		var target **string
+		switch member {
+		case "name":
+			target = &req.Name
+		case "email":
+			target = &req.Email
+		default:
+			fields = append(fields, domainerr.FieldError{
+				Field: member, Message: "is not a patchable field",
+			})
+			continue
+		}
+
+		var value *string
+		if err := json.Unmarshal(patch[member], &value); err != nil {
+			fields = append(fields, domainerr.FieldError{Field: member, Message: "must be a string"})
+			continue
+		}
+		if value == nil {
+			fields = append(fields, domainerr.FieldError{Field: member, Message: "must not be removed"})
+			continue
+		}
+		*target = value
+	}
+
+	if len(fields) > 0 {
+		return dto.PatchUserRequest{}, domainerr.Validation("invalid merge patch", fields...)
+	}
+	return req, nil
+}
+
+// Suggest serves type-ahead: it is meant to be called on every keystroke and
+// only returns the few fields needed to pick a user.
+func (h *UserHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	suggestions, err := h.useCase.Suggest(r.Context(), dto.SuggestUsersRequest{
+		Prefix: query.Get("prefix"),
+		Limit:  query.Get("limit"),
+	})
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error suggesting users: " + err.Error())
+		return
+	}
+
+	payload, err := json.Marshal(dto.NewUserSuggestionResponses(suggestions))
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error encoding suggestions: " + err.Error())
+		return
+	}
+
+	writeConditionalJSON(w, r, weakETag(payload), payload)
 }
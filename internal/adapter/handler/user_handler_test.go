@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/usecase"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRouter serves a UserHandler backed by the in-memory mocks of the
+// use case layer.
+func newTestRouter() (*mux.Router, usecase.IUserUseCase) {
+	useCase := usecase.NewUserUseCase(
+		usecase.SetupMockRepo(), usecase.SetupMockUnitOfWork(), usecase.SetupMockOutboxRepo(),
+	)
+	idempotency := usecase.NewIdempotencyUseCase(
+		usecase.SetupMockIdempotencyRepo(), time.Hour, time.Minute,
+	)
+	events := usecase.NewUserEventFeed(usecase.SetupMockOutboxRepo(), logger.NewLogger(), 1)
+	router := mux.NewRouter()
+	NewUserHandler(useCase, idempotency, events, logger.NewLogger(), time.UTC).RegisterRoutes(router)
+	return router, useCase
+}
+
+type patchHandlerTestCase struct {
+	testName        string
+	body            string
+	expectedStatus  int
+	expectedVersion int64
+}
+
+func TestUserHandler_Patch(t *testing.T) {
+	tests_scenarios := []patchHandlerTestCase{
+		{testName: "Name Changed", body: `{"name":"Jane Doe"}`, expectedStatus: http.StatusOK, expectedVersion: 2},
+		{testName: "Empty Object", body: `{}`, expectedStatus: http.StatusOK, expectedVersion: 1},
+		{testName: "Same Name", body: `{"name":"John Doe"}`, expectedStatus: http.StatusOK, expectedVersion: 1},
+		{testName: "Null", body: `null`, expectedStatus: http.StatusBadRequest, expectedVersion: 1},
+		{testName: "Array", body: `[]`, expectedStatus: http.StatusBadRequest, expectedVersion: 1},
+		{testName: "String", body: `"John"`, expectedStatus: http.StatusBadRequest, expectedVersion: 1},
+		{testName: "Malformed", body: `{"name":`, expectedStatus: http.StatusBadRequest, expectedVersion: 1},
+		{
+			testName:        "Removed Name",
+			body:            `{"name":null}`,
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedVersion: 1,
+		},
+		{
+			testName:        "Unknown Member",
+			body:            `{"version":5}`,
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedVersion: 1,
+		},
+		{
+			testName:        "Name Not A String",
+			body:            `{"name":42}`,
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedVersion: 1,
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			router, useCase := newTestRouter()
+			id, err := useCase.Add(context.Background(), dto.CreateUserRequest{
+				Name: "John Doe", Email: "john@example.com",
+			})
+			assert.NoError(t, err)
+
+			r := httptest.NewRequest(http.MethodPatch, "/users/"+id.String(), strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", mergePatchContentType)
+			r.Header.Set("If-Match", "*")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			stored, err := useCase.GetById(context.Background(), id)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedVersion, stored.Version, "should only write patches that change the user")
+		})
+	}
+}
+
+type batchHandlerTestCase struct {
+	testName       string
+	query          string
+	body           string
+	expectedStatus int
+	expectedItems  []int
+}
+
+func TestUserHandler_AddBatch(t *testing.T) {
+	const (
+		john    = `{"name":"John Doe","email":"john@example.com"}`
+		jane    = `{"name":"Jane Doe","email":"jane@example.com"}`
+		invalid = `{"name":"","email":"not-an-email"}`
+	)
+
+	tests_scenarios := []batchHandlerTestCase{
+		{
+			testName:       "All Created",
+			body:           "[" + john + "," + jane + "]",
+			expectedStatus: http.StatusCreated,
+			expectedItems:  []int{http.StatusCreated, http.StatusCreated},
+		},
+		{
+			testName:       "Atomic With Invalid User",
+			body:           "[" + john + "," + invalid + "]",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedItems:  []int{http.StatusFailedDependency, http.StatusUnprocessableEntity},
+		},
+		{
+			testName:       "Atomic With Duplicate Email",
+			query:          "?mode=atomic",
+			body:           "[" + john + "," + john + "]",
+			expectedStatus: http.StatusConflict,
+			expectedItems:  []int{http.StatusFailedDependency, http.StatusConflict},
+		},
+		{
+			testName:       "Best Effort With Invalid User",
+			query:          "?mode=best_effort",
+			body:           "[" + john + "," + invalid + "]",
+			expectedStatus: http.StatusMultiStatus,
+			expectedItems:  []int{http.StatusCreated, http.StatusUnprocessableEntity},
+		},
+		{
+			testName:       "Unknown Mode",
+			query:          "?mode=eventually",
+			body:           "[" + john + "]",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			testName:       "Not An Array",
+			body:           john,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			router, _ := newTestRouter()
+
+			r := httptest.NewRequest(http.MethodPost, "/users/batch"+tt.query, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expectedItems == nil {
+				return
+			}
+			var response dto.CreateUsersResponse
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			items := make([]int, 0, len(response.Results))
+			for i, item := range response.Results {
+				assert.Equal(t, i, item.Index, "should report items in request order")
+				assert.Equal(t, item.Status == http.StatusCreated, item.ID != nil && *item.ID != uuid.Nil,
+					"should only give created users an ID",
+				)
+				items = append(items, item.Status)
+			}
+			assert.Equal(t, tt.expectedItems, items)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Clean Architecture - Interface Adapter Layer
+// HTTP Handlers for Webhooks
+package handler
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/usecase"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type WebhookHandler struct {
+	useCase  usecase.IWebhookUseCase
+	logger   logger.ILogger
+	location *time.Location
+}
+
+// NewWebhookHandler renders webhook timestamps in location.
+func NewWebhookHandler(
+	useCase usecase.IWebhookUseCase, logger logger.ILogger, location *time.Location,
+) *WebhookHandler {
+	return &WebhookHandler{useCase: useCase, logger: logger, location: location}
+}
+
+func (h *WebhookHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/webhooks", h.Subscribe).Methods(http.MethodPost)
+	r.HandleFunc("/webhooks/{id}/deliveries", h.Deliveries).Methods(http.MethodGet)
+}
+
+func (h *WebhookHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		h.logger.Error("Error decoding request body: " + err.Error())
+		return
+	}
+
+	h.logger.Info("Received request to create webhook subscription")
+	subscription, err := h.useCase.Subscribe(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error creating webhook subscription: " + err.Error())
+		return
+	}
+
+	h.logger.Info(fmt.Sprintf("Webhook subscription created successfully with id: %s", subscription.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewWebhookSubscriptionResponse(subscription, h.location))
+}
+
+func (h *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid ID format")
+		h.logger.Error("Error parsing ID: " + err.Error())
+		return
+	}
+
+	h.logger.Info(fmt.Sprintf("Received request to list deliveries of webhook subscription %s", id))
+	deliveries, err := h.useCase.Deliveries(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		h.logger.Error("Error listing webhook deliveries: " + err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewWebhookDeliveriesResponse(deliveries, h.location))
+}
@@ -0,0 +1,24 @@
+// Clean Architecture - Interface Adapter Layer
+// EventPublisher that writes events to the log
+package publisher
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"fmt"
+)
+
+// LogPublisher stands in for a message broker by logging every event.
+type LogPublisher struct {
+	logger logger.ILogger
+}
+
+func NewLogPublisher(logger logger.ILogger) *LogPublisher {
+	return &LogPublisher{logger: logger}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	p.logger.Info(fmt.Sprintf("Event %s %s for %s: %s", event.Type, event.ID, event.AggregateID, event.Payload))
+	return nil
+}
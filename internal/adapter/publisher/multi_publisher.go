@@ -0,0 +1,28 @@
+// Clean Architecture - Interface Adapter Layer
+// EventPublisher that hands events to several publishers
+package publisher
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+)
+
+// MultiPublisher publishes every event to each of its publishers in turn,
+// stopping at the first that fails. As the event is then published again,
+// to all of them, publishers must tolerate duplicates.
+type MultiPublisher struct {
+	publishers []entity.IEventPublisher
+}
+
+func NewMultiPublisher(publishers ...entity.IEventPublisher) *MultiPublisher {
+	return &MultiPublisher{publishers: publishers}
+}
+
+func (p *MultiPublisher) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
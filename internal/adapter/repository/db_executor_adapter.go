@@ -1,7 +1,11 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"io"
+
+	"github.com/lib/pq"
 )
 
 type dbExecutorAdapter struct {
@@ -12,20 +16,20 @@ func NewDBExecutorAdapter(db *sql.DB) DBExecutor {
 	return &dbExecutorAdapter{db: db}
 }
 
-func (a *dbExecutorAdapter) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return a.db.Exec(query, args...)
+func (a *dbExecutorAdapter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return a.db.ExecContext(ctx, query, args...)
 }
 
-func (a *dbExecutorAdapter) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return a.db.Query(query, args...)
+func (a *dbExecutorAdapter) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return a.db.QueryContext(ctx, query, args...)
 }
 
-func (a *dbExecutorAdapter) QueryRow(query string, args ...interface{}) *sql.Row {
-	return a.db.QueryRow(query, args...)
+func (a *dbExecutorAdapter) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return a.db.QueryRowContext(ctx, query, args...)
 }
 
-func (a *dbExecutorAdapter) Begin() (TxExecutor, error) {
-	tx, err := a.db.Begin()
+func (a *dbExecutorAdapter) BeginTx(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+	tx, err := a.db.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -36,8 +40,46 @@ type txExecutorAdapter struct {
 	tx *sql.Tx
 }
 
-func (t *txExecutorAdapter) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return t.tx.Exec(query, args...)
+func (t *txExecutorAdapter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+func (t *txExecutorAdapter) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+func (t *txExecutorAdapter) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+func (t *txExecutorAdapter) CopyFrom(
+	ctx context.Context, table string, columns []string, next func() ([]interface{}, error),
+) (int64, error) {
+	stmt, err := t.tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var copied int64
+	for {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return copied, err
+		}
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return copied, err
+		}
+		copied++
+	}
+	// An Exec without arguments flushes the buffered rows.
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return copied, err
+	}
+	return copied, nil
 }
 
 func (t *txExecutorAdapter) Rollback() error {
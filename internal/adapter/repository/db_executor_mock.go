@@ -1,40 +1,52 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 )
 
+// DBExecutorMock fails every call with ctx.Err() once the context is done,
+// mirroring database/sql, so cancellation can be asserted in tests.
 type DBExecutorMock struct {
-	ExecFunc     func(query string, args ...interface{}) (sql.Result, error)
-	QueryFunc    func(query string, args ...interface{}) (*sql.Rows, error)
-	QueryRowFunc func(query string, args ...interface{}) *sql.Row
-	BeginFunc    func() (TxExecutor, error)
+	ExecContextFunc     func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContextFunc    func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContextFunc func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	BeginTxFunc         func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error)
 }
 
-func (m *DBExecutorMock) Exec(query string, args ...interface{}) (sql.Result, error) {
-	if m.ExecFunc != nil {
-		return m.ExecFunc(query, args...)
+func (m *DBExecutorMock) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.ExecContextFunc != nil {
+		return m.ExecContextFunc(ctx, query, args...)
 	}
 	return nil, nil
 }
 
-func (m *DBExecutorMock) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	if m.QueryFunc != nil {
-		return m.QueryFunc(query, args...)
+func (m *DBExecutorMock) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.QueryContextFunc != nil {
+		return m.QueryContextFunc(ctx, query, args...)
 	}
 	return nil, nil
 }
 
-func (m *DBExecutorMock) QueryRow(query string, args ...interface{}) *sql.Row {
-	if m.QueryRowFunc != nil {
-		return m.QueryRowFunc(query, args...)
+func (m *DBExecutorMock) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if m.QueryRowContextFunc != nil {
+		return m.QueryRowContextFunc(ctx, query, args...)
 	}
 	return &sql.Row{}
 }
 
-func (m *DBExecutorMock) Begin() (TxExecutor, error) {
-	if m.BeginFunc != nil {
-		return m.BeginFunc()
+func (m *DBExecutorMock) BeginTx(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.BeginTxFunc != nil {
+		return m.BeginTxFunc(ctx, opts)
 	}
 	return nil, nil
 }
@@ -1,20 +1,71 @@
 package repository
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"io"
+)
 
 type TxMock struct {
-	ExecFunc     func(query string, args ...interface{}) (sql.Result, error)
-	RollbackFunc func() error
-	CommitFunc   func() error
+	ExecContextFunc     func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContextFunc    func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContextFunc func(ctx context.Context, query string, args ...interface{}) *sql.Row
+	RollbackFunc        func() error
+	CommitFunc          func() error
+	CopyFromFunc        func(
+		ctx context.Context, table string, columns []string, next func() ([]interface{}, error),
+	) (int64, error)
 }
 
-func (m *TxMock) Exec(query string, args ...interface{}) (sql.Result, error) {
-	if m.ExecFunc != nil {
-		return m.ExecFunc(query, args...)
+func (m *TxMock) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.ExecContextFunc != nil {
+		return m.ExecContextFunc(ctx, query, args...)
 	}
 	return nil, nil
 }
 
+func (m *TxMock) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.QueryContextFunc != nil {
+		return m.QueryContextFunc(ctx, query, args...)
+	}
+	return nil, nil
+}
+
+func (m *TxMock) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if m.QueryRowContextFunc != nil {
+		return m.QueryRowContextFunc(ctx, query, args...)
+	}
+	return &sql.Row{}
+}
+
+// CopyFrom drains next when no CopyFromFunc is set, so that callers still
+// see the rows they produce.
+func (m *TxMock) CopyFrom(
+	ctx context.Context, table string, columns []string, next func() ([]interface{}, error),
+) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if m.CopyFromFunc != nil {
+		return m.CopyFromFunc(ctx, table, columns, next)
+	}
+	var copied int64
+	for {
+		if _, err := next(); err == io.EOF {
+			return copied, nil
+		} else if err != nil {
+			return copied, err
+		}
+		copied++
+	}
+}
+
 func (m *TxMock) Rollback() error {
 	if m.RollbackFunc != nil {
 		return m.RollbackFunc()
@@ -0,0 +1,30 @@
+// Clean Architecture - Interface Adapter Layer
+// EventPublisher announcing published events to every instance
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+)
+
+// UserEventNotifier announces, with exec, that event was published.
+type UserEventNotifier interface {
+	NotifyUserEvent(ctx context.Context, exec Execer, event entity.OutboxEvent) error
+}
+
+// PostgresEventPublisher publishes events by announcing them in the unit of
+// work of the dispatcher. They are thus only announced once marked
+// delivered, and reach the feeds of every instance, whichever dispatched
+// them.
+type PostgresEventPublisher struct {
+	db       DBExecutor
+	notifier UserEventNotifier
+}
+
+func NewPostgresEventPublisher(db DBExecutor, notifier UserEventNotifier) *PostgresEventPublisher {
+	return &PostgresEventPublisher{db: db, notifier: notifier}
+}
+
+func (p *PostgresEventPublisher) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	return p.notifier.NotifyUserEvent(ctx, conn(ctx, p.db), event)
+}
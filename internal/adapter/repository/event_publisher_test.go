@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// userEventNotifierMock records the events it is asked to announce and what
+// it was asked to announce them with.
+type userEventNotifierMock struct {
+	events  []entity.OutboxEvent
+	execers []Execer
+}
+
+func (m *userEventNotifierMock) NotifyUserEvent(ctx context.Context, exec Execer, event entity.OutboxEvent) error {
+	m.events = append(m.events, event)
+	m.execers = append(m.execers, exec)
+	return nil
+}
+
+func TestPostgresEventPublisher_Publish(t *testing.T) {
+	log := &txLog{}
+	db := &DBExecutorMock{
+		BeginTxFunc: func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+			return newLoggedTx(log), nil
+		},
+	}
+	notifier := &userEventNotifierMock{}
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	event := entity.NewUserEvent(entity.UserCreated, nil, &user)
+
+	publisher := NewPostgresEventPublisher(db, notifier)
+	err := NewPostgresUnitOfWork(db).Do(context.Background(), entity.TxOptions{}, func(ctx context.Context) error {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+		_, err := notifier.execers[0].ExecContext(ctx, "SELECT pg_notify($1, $2)")
+		return err
+	})
+
+	assert.NoError(t, err, "Expected no error for a publication")
+	assert.Equal(t, []entity.OutboxEvent{event}, notifier.events)
+	assert.Equal(t, []string{"SELECT pg_notify($1, $2)"}, log.statements,
+		"Expected the event to be announced in the unit of work of the dispatcher",
+	)
+	assert.Equal(t, 1, log.commits)
+}
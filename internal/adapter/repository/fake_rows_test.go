@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+)
+
+// The fake driver below lets tests hand real *sql.Rows and *sql.Row values
+// to DBExecutorMock and TxMock without a database.
+
+type fakeConnector struct {
+	columns []string
+	values  [][]driver.Value
+	err     error
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{connector: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fake driver: use a connector")
+}
+
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver: prepare not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fake driver: transactions not supported")
+}
+
+func (c *fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	if c.connector.err != nil {
+		return nil, c.connector.err
+	}
+	return &fakeRows{columns: c.connector.columns, values: c.connector.values}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+func openFakeDB(t *testing.T, connector *fakeConnector) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newRows returns rows holding values, one slice per row.
+func newRows(t *testing.T, columns []string, values ...[]driver.Value) *sql.Rows {
+	t.Helper()
+	rows, err := openFakeDB(t, &fakeConnector{columns: columns, values: values}).Query("fake")
+	if err != nil {
+		t.Fatalf("fake driver: %v", err)
+	}
+	return rows
+}
+
+// newRow returns a single row; with no values it yields sql.ErrNoRows.
+func newRow(t *testing.T, columns []string, values ...[]driver.Value) *sql.Row {
+	t.Helper()
+	return openFakeDB(t, &fakeConnector{columns: columns, values: values}).QueryRow("fake")
+}
+
+// newErrorRow returns a row whose Scan fails with err.
+func newErrorRow(t *testing.T, err error) *sql.Row {
+	t.Helper()
+	return openFakeDB(t, &fakeConnector{err: err}).QueryRow("fake")
+}
@@ -0,0 +1,121 @@
+// Clean Architecture - Interface Adapter Layer
+// IdempotencyRepository implementation for PostgreSQL
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type PostgresIdempotencyRepository struct {
+	db DBExecutor
+}
+
+func NewPostgresIdempotencyRepository(db DBExecutor) *PostgresIdempotencyRepository {
+	return &PostgresIdempotencyRepository{db: db}
+}
+
+// Claim relies on the primary key: of concurrent requests with the same key,
+// only one inserts the row and the others wait for it, then find it taken.
+// An expired key, or one whose request outlived its lock, is taken over in
+// the same statement.
+func (r *PostgresIdempotencyRepository) Claim(
+	ctx context.Context, key entity.IdempotencyKey,
+) (entity.IdempotencyKey, bool, error) {
+	var claimed bool
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		`INSERT INTO idempotency_keys (scope, key, request_hash, created_at, locked_until, expires_at)
+		VALUES ($1, $2, $3, now(), $4, $5)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash, status_code = NULL,
+			response_headers = NULL, response_body = NULL, created_at = now(),
+			locked_until = EXCLUDED.locked_until, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= now()
+			OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until <= now()
+				AND idempotency_keys.request_hash = EXCLUDED.request_hash)
+		RETURNING true`,
+		key.Scope, key.Key, key.RequestHash, key.LockedUntil, key.ExpiresAt,
+	).Scan(&claimed)
+	if err == nil {
+		return key, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return entity.IdempotencyKey{}, false, err
+	}
+
+	existing := entity.IdempotencyKey{Scope: key.Scope, Key: key.Key}
+	var status sql.NullInt64
+	var header, body []byte
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		`SELECT request_hash, status_code, response_headers, response_body, locked_until, expires_at
+		FROM idempotency_keys WHERE scope = $1 AND key = $2 AND expires_at > now()`,
+		key.Scope, key.Key,
+	).Scan(&existing.RequestHash, &status, &header, &body, &existing.LockedUntil, &existing.ExpiresAt)
+	if err == sql.ErrNoRows {
+		// Released or expired since the insert: all a retry can tell is that
+		// the key is not free yet, as if its request were still in progress.
+		return existing, false, nil
+	}
+	if err != nil {
+		return entity.IdempotencyKey{}, false, err
+	}
+
+	if status.Valid {
+		existing.Response = &entity.IdempotentResponse{StatusCode: int(status.Int64), Body: body}
+		if len(header) > 0 {
+			if err := json.Unmarshal(header, &existing.Response.Header); err != nil {
+				return entity.IdempotencyKey{}, false, err
+			}
+		}
+	}
+	return existing, false, nil
+}
+
+func (r *PostgresIdempotencyRepository) Complete(
+	ctx context.Context, scope, key string, response entity.IdempotentResponse,
+) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+	body := response.Body
+	if body == nil {
+		body = []byte{}
+	}
+	_, err = conn(ctx, r.db).ExecContext(
+		ctx,
+		`UPDATE idempotency_keys SET status_code = $3, response_headers = $4, response_body = $5
+		WHERE scope = $1 AND key = $2`,
+		scope, key, response.StatusCode, string(header), body,
+	)
+	return err
+}
+
+// Release only removes keys still in progress, never a stored response.
+func (r *PostgresIdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status_code IS NULL`,
+		scope, key,
+	)
+	return err
+}
+
+func (r *PostgresIdempotencyRepository) PurgeExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE (scope, key) IN (
+			SELECT scope, key FROM idempotency_keys WHERE expires_at < $1 LIMIT $2
+		)`,
+		before, limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
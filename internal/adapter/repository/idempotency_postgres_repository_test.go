@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type idempotencyTestCase struct {
+	testName  string
+	repoSetup func(*DBExecutorMock)
+	expected  error
+}
+
+func TestIdempotencyRepository_Claim(t *testing.T) {
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	lockedUntil := time.Date(2024, 1, 1, 3, 5, 5, 0, time.UTC)
+	key := entity.IdempotencyKey{
+		Scope: "POST /users", Key: "abc", RequestHash: "hash", LockedUntil: lockedUntil, ExpiresAt: expiresAt,
+	}
+	storedColumns := []string{
+		"request_hash", "status_code", "response_headers", "response_body", "locked_until", "expires_at",
+	}
+
+	// notClaimed answers the insert with no row and the lookup with stored.
+	notClaimed := func(stored ...[]driver.Value) func(*DBExecutorMock) {
+		return func(repo *DBExecutorMock) {
+			calls := 0
+			repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+				calls++
+				if calls == 1 {
+					return newRow(t, []string{"claimed"})
+				}
+				return newRow(t, storedColumns, stored...)
+			}
+		}
+	}
+
+	tests_scenarios := []idempotencyTestCase{
+		{
+			testName: "Key Claimed",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					assert.Contains(t, query, "idempotency_keys.locked_until <= now()",
+						"Expected a key whose lock lapsed to be taken over")
+					assert.Equal(t, []interface{}{"POST /users", "abc", "hash", lockedUntil, expiresAt}, args)
+					return newRow(t, []string{"claimed"}, []driver.Value{true})
+				}
+			},
+		},
+		{
+			testName: "Response Stored",
+			repoSetup: notClaimed([]driver.Value{
+				"hash", int64(201), []byte(`{"Content-Type":["application/json"]}`), []byte(`{"id":"1"}`),
+				lockedUntil, expiresAt,
+			}),
+		},
+		{
+			testName:  "Request In Progress",
+			repoSetup: notClaimed([]driver.Value{"hash", nil, nil, nil, lockedUntil, expiresAt}),
+		},
+		{
+			testName:  "Key Released Meanwhile",
+			repoSetup: notClaimed(),
+		},
+		{
+			testName: "Error on Claim",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newErrorRow(t, errors.New("database error"))
+				}
+			},
+			expected: errors.New("database error"),
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			dbExecutor := &DBExecutorMock{}
+			tt.repoSetup(dbExecutor)
+
+			repo := NewPostgresIdempotencyRepository(dbExecutor)
+			existing, claimed, err := repo.Claim(context.Background(), key)
+
+			switch tt.testName {
+			case tests_scenarios[0].testName:
+				assert.NoError(t, err, "Expected no error for a free key")
+				assert.True(t, claimed, "Expected the key to be claimed")
+			case tests_scenarios[1].testName:
+				assert.NoError(t, err, "Expected no error for a completed key")
+				assert.False(t, claimed, "Expected the key to be taken")
+				assert.Equal(t, &entity.IdempotentResponse{
+					StatusCode: 201,
+					Header:     map[string][]string{"Content-Type": {"application/json"}},
+					Body:       []byte(`{"id":"1"}`),
+				}, existing.Response, "Expected the stored response")
+			case tests_scenarios[2].testName, tests_scenarios[3].testName:
+				assert.NoError(t, err, "Expected no error for a key in progress")
+				assert.False(t, claimed, "Expected the key to be taken")
+				assert.Nil(t, existing.Response, "Expected no response yet")
+			case tests_scenarios[4].testName:
+				assert.EqualError(t, err, tt.expected.Error(), "Expected error message to match")
+			}
+		})
+	}
+}
+
+func TestIdempotencyRepository_Complete(t *testing.T) {
+	var gotArgs []interface{}
+	dbExecutor := &DBExecutorMock{
+		ExecContextFunc: func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			gotArgs = args
+			return SQLResultMock{RowsAffectedValue: 1}, nil
+		},
+	}
+
+	repo := NewPostgresIdempotencyRepository(dbExecutor)
+	err := repo.Complete(context.Background(), "POST /users", "abc", entity.IdempotentResponse{
+		StatusCode: 201,
+		Header:     map[string][]string{"Content-Type": {"application/json"}},
+		Body:       []byte(`{"id":"1"}`),
+	})
+
+	assert.NoError(t, err, "Expected no error for a completion")
+	assert.Equal(t, []interface{}{
+		"POST /users", "abc", 201, `{"Content-Type":["application/json"]}`, []byte(`{"id":"1"}`),
+	}, gotArgs)
+}
+
+func TestIdempotencyRepository_PurgeExpired(t *testing.T) {
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var gotArgs []interface{}
+	dbExecutor := &DBExecutorMock{
+		ExecContextFunc: func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			gotArgs = args
+			return SQLResultMock{RowsAffectedValue: 3}, nil
+		},
+	}
+
+	repo := NewPostgresIdempotencyRepository(dbExecutor)
+	purged, err := repo.PurgeExpired(context.Background(), before, 100)
+
+	assert.NoError(t, err, "Expected no error for a purge")
+	assert.Equal(t, int64(3), purged, "Expected the number of purged keys")
+	assert.Equal(t, []interface{}{before, 100}, gotArgs)
+}
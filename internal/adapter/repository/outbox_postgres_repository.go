@@ -0,0 +1,136 @@
+// Clean Architecture - Interface Adapter Layer
+// OutboxRepository implementation for PostgreSQL
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type PostgresOutboxRepository struct {
+	db DBExecutor
+}
+
+func NewPostgresOutboxRepository(db DBExecutor) *PostgresOutboxRepository {
+	return &PostgresOutboxRepository{db: db}
+}
+
+func (r *PostgresOutboxRepository) Add(ctx context.Context, events ...entity.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(events))
+	args := make([]interface{}, 0, 5*len(events))
+	for _, event := range events {
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		// The payload is sent as text: lib/pq would send []byte as bytea.
+		args = append(args, event.ID, event.Type, event.AggregateID, string(event.Payload), event.OccurredAt)
+	}
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		"INSERT INTO outbox (id, type, aggregate_id, payload, occurred_at) VALUES "+strings.Join(values, ", "),
+		args...,
+	)
+	return err
+}
+
+// scanOutboxEvents reads events selected with every column of OutboxEvent.
+func scanOutboxEvents(rows *sql.Rows) ([]entity.OutboxEvent, error) {
+	defer rows.Close()
+
+	var events []entity.OutboxEvent
+	for rows.Next() {
+		var event entity.OutboxEvent
+		err := rows.Scan(&event.ID, &event.Type, &event.AggregateID, &event.Payload, &event.OccurredAt, &event.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// ClaimPending relies on FOR UPDATE SKIP LOCKED so that several dispatchers
+// can share the outbox, each publishing different events. Of the events it
+// locks, those with an older pending event of the same aggregate that it did
+// not lock, held by another dispatcher, are left out: they stay locked until
+// the unit of work ends, but are not published ahead of that event.
+func (r *PostgresOutboxRepository) ClaimPending(ctx context.Context, limit int) ([]entity.OutboxEvent, error) {
+	rows, err := conn(ctx, r.db).QueryContext(
+		ctx,
+		`WITH claimed AS (
+			SELECT id, type, aggregate_id, payload, occurred_at, attempts FROM outbox
+			WHERE delivered_at IS NULL AND failed_at IS NULL
+			ORDER BY occurred_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		SELECT id, type, aggregate_id, payload, occurred_at, attempts FROM claimed
+		WHERE NOT EXISTS (
+			SELECT 1 FROM outbox older
+			WHERE older.aggregate_id = claimed.aggregate_id
+				AND older.delivered_at IS NULL AND older.failed_at IS NULL
+				AND (older.occurred_at, older.id) < (claimed.occurred_at, claimed.id)
+				AND older.id NOT IN (SELECT id FROM claimed)
+		)
+		ORDER BY occurred_at, id`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanOutboxEvents(rows)
+}
+
+// LatestDelivered orders the events delivered together, which share their
+// delivered_at, as the dispatcher published them.
+func (r *PostgresOutboxRepository) LatestDelivered(ctx context.Context, limit int) ([]entity.OutboxEvent, error) {
+	rows, err := conn(ctx, r.db).QueryContext(
+		ctx,
+		`SELECT id, type, aggregate_id, payload, occurred_at, attempts FROM (
+			SELECT * FROM outbox
+			WHERE delivered_at IS NOT NULL
+			ORDER BY delivered_at DESC, occurred_at DESC, id DESC
+			LIMIT $1
+		) latest
+		ORDER BY delivered_at, occurred_at, id`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanOutboxEvents(rows)
+}
+
+func (r *PostgresOutboxRepository) MarkDelivered(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		"UPDATE outbox SET delivered_at = now() WHERE id = ANY($1::uuid[])",
+		pq.Array(keys),
+	)
+	return err
+}
+
+func (r *PostgresOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, reason string, giveUp bool) error {
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = $2, failed_at = CASE WHEN $3 THEN now() END
+		WHERE id = $1`,
+		id, reason, giveUp,
+	)
+	return err
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxRepository_Add(t *testing.T) {
+	events := []entity.OutboxEvent{
+		{ID: uuid.New(), Type: entity.UserCreated, AggregateID: uuid.New(), Payload: []byte(`{"before":null}`)},
+		{ID: uuid.New(), Type: entity.UserDeleted, AggregateID: uuid.New(), Payload: []byte(`{"after":null}`)},
+	}
+	var gotQuery string
+	var gotArgs []interface{}
+	dbExecutor := &DBExecutorMock{
+		ExecContextFunc: func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			gotQuery, gotArgs = query, args
+			return SQLResultMock{RowsAffectedValue: 2}, nil
+		},
+	}
+
+	repo := NewPostgresOutboxRepository(dbExecutor)
+	err := repo.Add(context.Background(), events...)
+
+	assert.NoError(t, err, "Expected no error for an insert")
+	assert.Contains(t, gotQuery, "VALUES ($1, $2, $3, $4, $5), ($6, $7, $8, $9, $10)")
+	assert.Equal(t, `{"before":null}`, gotArgs[3], "Expected the payload to be sent as text")
+	assert.Len(t, gotArgs, 10)
+}
+
+func TestOutboxRepository_ClaimPending(t *testing.T) {
+	stored := entity.OutboxEvent{
+		ID:          uuid.New(),
+		Type:        entity.UserUpdated,
+		AggregateID: uuid.New(),
+		Payload:     []byte(`{"before":null,"after":null}`),
+		OccurredAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Attempts:    2,
+	}
+	var gotQuery string
+	dbExecutor := &DBExecutorMock{
+		QueryContextFunc: func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+			gotQuery = query
+			return newRows(t, []string{"id", "type", "aggregate_id", "payload", "occurred_at", "attempts"},
+				[]driver.Value{
+					stored.ID.String(), stored.Type, stored.AggregateID.String(),
+					stored.Payload, stored.OccurredAt, int64(stored.Attempts),
+				},
+			), nil
+		},
+	}
+
+	repo := NewPostgresOutboxRepository(dbExecutor)
+	events, err := repo.ClaimPending(context.Background(), 10)
+
+	assert.NoError(t, err, "Expected no error for a claim")
+	assert.Equal(t, []entity.OutboxEvent{stored}, events)
+	assert.Contains(t, gotQuery, "FOR UPDATE SKIP LOCKED", "Expected claimed events to be skipped by others")
+	assert.Contains(t, gotQuery, "failed_at IS NULL", "Expected events given up on not to be claimed")
+	assert.Contains(t, gotQuery, "older.id NOT IN (SELECT id FROM claimed)",
+		"Expected events behind an older one claimed by another dispatcher to be held back")
+}
+
+func TestOutboxRepository_MarkDelivered(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	var gotArgs []interface{}
+	dbExecutor := &DBExecutorMock{
+		ExecContextFunc: func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			gotArgs = args
+			return SQLResultMock{RowsAffectedValue: 2}, nil
+		},
+	}
+
+	repo := NewPostgresOutboxRepository(dbExecutor)
+	err := repo.MarkDelivered(context.Background(), ids)
+
+	assert.NoError(t, err, "Expected no error for an update")
+	assert.Equal(t, []interface{}{pq.Array([]string{ids[0].String(), ids[1].String()})}, gotArgs)
+}
+
+func TestOutboxRepository_MarkFailed(t *testing.T) {
+	for _, giveUp := range []bool{false, true} {
+		id := uuid.New()
+		var gotQuery string
+		var gotArgs []interface{}
+		dbExecutor := &DBExecutorMock{
+			ExecContextFunc: func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+				gotQuery, gotArgs = query, args
+				return SQLResultMock{RowsAffectedValue: 1}, nil
+			},
+		}
+
+		repo := NewPostgresOutboxRepository(dbExecutor)
+		err := repo.MarkFailed(context.Background(), id, "broker unavailable", giveUp)
+
+		assert.NoError(t, err, "Expected no error for an update")
+		assert.Contains(t, gotQuery, "attempts = attempts + 1", "Expected the attempt to be counted")
+		assert.Contains(t, gotQuery, "failed_at = CASE WHEN $3 THEN now() END")
+		assert.Equal(t, []interface{}{id, "broker unavailable", giveUp}, gotArgs)
+	}
+}
+
+func TestOutboxRepository_LatestDelivered(t *testing.T) {
+	stored := entity.OutboxEvent{
+		ID:          uuid.New(),
+		Type:        entity.UserCreated,
+		AggregateID: uuid.New(),
+		Payload:     []byte(`{"before":null,"after":null}`),
+		OccurredAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	var gotQuery string
+	var gotArgs []interface{}
+	dbExecutor := &DBExecutorMock{
+		QueryContextFunc: func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+			gotQuery, gotArgs = query, args
+			return newRows(t, []string{"id", "type", "aggregate_id", "payload", "occurred_at", "attempts"},
+				[]driver.Value{
+					stored.ID.String(), stored.Type, stored.AggregateID.String(),
+					stored.Payload, stored.OccurredAt, int64(0),
+				},
+			), nil
+		},
+	}
+
+	repo := NewPostgresOutboxRepository(dbExecutor)
+	events, err := repo.LatestDelivered(context.Background(), 10)
+
+	assert.NoError(t, err, "Expected no error for a read")
+	assert.Equal(t, []entity.OutboxEvent{stored}, events)
+	assert.Contains(t, gotQuery, "delivered_at IS NOT NULL", "Expected only delivered events")
+	assert.Contains(t, gotQuery, "ORDER BY delivered_at DESC, occurred_at DESC, id DESC", "Expected the latest events")
+	assert.Equal(t, []interface{}{10}, gotArgs)
+}
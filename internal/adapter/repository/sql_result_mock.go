@@ -0,0 +1,15 @@
+package repository
+
+type SQLResultMock struct {
+	LastInsertIDValue int64
+	RowsAffectedValue int64
+	Err               error
+}
+
+func (m SQLResultMock) LastInsertId() (int64, error) {
+	return m.LastInsertIDValue, m.Err
+}
+
+func (m SQLResultMock) RowsAffected() (int64, error) {
+	return m.RowsAffectedValue, m.Err
+}
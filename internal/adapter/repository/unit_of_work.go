@@ -0,0 +1,165 @@
+// Clean Architecture - Interface Adapter Layer
+// UnitOfWork implementation for PostgreSQL
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// maxTxAttempts bounds how many times a unit of work is run when the
+// database keeps aborting it.
+const maxTxAttempts = 3
+
+// SQLSTATEs of transactions aborted by the database, which succeed when
+// simply run again.
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+var txIsolationLevels = map[entity.TxIsolation]sql.IsolationLevel{
+	entity.TxIsolationDefault:        sql.LevelDefault,
+	entity.TxIsolationReadCommitted:  sql.LevelReadCommitted,
+	entity.TxIsolationRepeatableRead: sql.LevelRepeatableRead,
+	entity.TxIsolationSerializable:   sql.LevelSerializable,
+}
+
+type txContextKey struct{}
+
+// txState is the transaction a context runs in. savepoints counts the
+// savepoints taken in the whole transaction, nested units of work included,
+// so that no two share a name.
+type txState struct {
+	tx         TxExecutor
+	savepoints *int
+}
+
+type PostgresUnitOfWork struct {
+	db DBExecutor
+}
+
+func NewPostgresUnitOfWork(db DBExecutor) *PostgresUnitOfWork {
+	return &PostgresUnitOfWork{db: db}
+}
+
+func (u *PostgresUnitOfWork) Do(ctx context.Context, opts entity.TxOptions, fn func(ctx context.Context) error) error {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return runTx(ctx, state.savepoint, fn)
+	}
+
+	begin := func(ctx context.Context) (TxExecutor, error) {
+		return u.db.BeginTx(ctx, &sql.TxOptions{
+			Isolation: txIsolationLevels[opts.Isolation],
+			ReadOnly:  opts.ReadOnly,
+		})
+	}
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, begin, fn)
+		if ctx.Err() != nil {
+			return err
+		}
+		if attempt == maxTxAttempts || !isTxAborted(err) {
+			return unavailableError(err, "the database is unavailable")
+		}
+	}
+}
+
+// runTx runs fn in the transaction returned by begin, bound to the context.
+func runTx(
+	ctx context.Context, begin func(context.Context) (TxExecutor, error), fn func(ctx context.Context) error,
+) error {
+	tx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	state := &txState{tx: tx, savepoints: new(int)}
+	if parent, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		state.savepoints = parent.savepoints
+	}
+	if err := fn(context.WithValue(ctx, txContextKey{}, state)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func isTxAborted(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected)
+}
+
+// conn is what repositories run their statements on: the transaction of the
+// unit of work ctx belongs to, if any, or db otherwise.
+func conn(ctx context.Context, db DBExecutor) DBExecutor {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return &txConn{state: state}
+	}
+	return db
+}
+
+// txConn runs statements in a transaction. The transactions it begins are
+// savepoints of that transaction, so repositories that manage their own
+// transaction compose with a unit of work.
+type txConn struct {
+	state *txState
+}
+
+func (c *txConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.state.tx.ExecContext(ctx, query, args...)
+}
+
+func (c *txConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.state.tx.QueryContext(ctx, query, args...)
+}
+
+func (c *txConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.state.tx.QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx ignores opts: a savepoint runs under its transaction's options.
+func (c *txConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+	return c.state.savepoint(ctx)
+}
+
+func (s *txState) savepoint(ctx context.Context) (TxExecutor, error) {
+	*s.savepoints++
+	name := fmt.Sprintf("sp_%d", *s.savepoints)
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, err
+	}
+	return &savepointTx{TxExecutor: s.tx, name: name}, nil
+}
+
+// savepointTx is a transaction nested in another through a savepoint.
+// Committing releases the savepoint and rolling back returns to it; either
+// leaves the enclosing transaction open.
+type savepointTx struct {
+	TxExecutor
+	name string
+	done bool
+}
+
+func (t *savepointTx) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.TxExecutor.ExecContext(context.Background(), "RELEASE SAVEPOINT "+t.name)
+	return err
+}
+
+func (t *savepointTx) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.TxExecutor.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT "+t.name)
+	return err
+}
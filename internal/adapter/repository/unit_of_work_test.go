@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// txLog records what a TxMock went through.
+type txLog struct {
+	statements []string
+	commits    int
+	rollbacks  int
+}
+
+func newLoggedTx(log *txLog) *TxMock {
+	return &TxMock{
+		ExecContextFunc: func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			log.statements = append(log.statements, query)
+			return SQLResultMock{RowsAffectedValue: 1}, nil
+		},
+		CommitFunc: func() error { log.commits++; return nil },
+		// Like sql.Tx, a committed transaction can no longer be rolled back.
+		RollbackFunc: func() error {
+			if log.commits > 0 {
+				return sql.ErrTxDone
+			}
+			log.rollbacks++
+			return nil
+		},
+	}
+}
+
+type unitOfWorkTestCase struct {
+	testName           string
+	fn                 func(uow *PostgresUnitOfWork) func(ctx context.Context) error
+	expected           error
+	expectedStatements []string
+	expectedCommits    int
+	expectedRollbacks  int
+}
+
+func TestUnitOfWork_Do(t *testing.T) {
+	failure := errors.New("failure")
+	exec := func(ctx context.Context, query string) error {
+		_, err := conn(ctx, &DBExecutorMock{}).ExecContext(ctx, query)
+		return err
+	}
+
+	tests_scenarios := []unitOfWorkTestCase{
+		{
+			testName: "Commit",
+			fn: func(*PostgresUnitOfWork) func(ctx context.Context) error {
+				return func(ctx context.Context) error { return exec(ctx, "UPDATE users") }
+			},
+			expectedStatements: []string{"UPDATE users"},
+			expectedCommits:    1,
+		},
+		{
+			testName: "Rollback",
+			fn: func(*PostgresUnitOfWork) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					exec(ctx, "UPDATE users")
+					return failure
+				}
+			},
+			expected:           failure,
+			expectedStatements: []string{"UPDATE users"},
+			expectedRollbacks:  1,
+		},
+		{
+			testName: "Nested Failure Rolls Back To Savepoint",
+			fn: func(uow *PostgresUnitOfWork) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					err := uow.Do(ctx, entity.TxOptions{}, func(ctx context.Context) error {
+						exec(ctx, "UPDATE users")
+						return failure
+					})
+					assert.ErrorIs(t, err, failure)
+					return exec(ctx, "DELETE FROM users")
+				}
+			},
+			expectedStatements: []string{
+				"SAVEPOINT sp_1", "UPDATE users", "ROLLBACK TO SAVEPOINT sp_1", "DELETE FROM users",
+			},
+			expectedCommits: 1,
+		},
+		{
+			testName: "Savepoints Nested Twice Have Their Own Names",
+			fn: func(uow *PostgresUnitOfWork) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					return uow.Do(ctx, entity.TxOptions{}, func(ctx context.Context) error {
+						err := uow.Do(ctx, entity.TxOptions{}, func(ctx context.Context) error {
+							exec(ctx, "UPDATE users")
+							return failure
+						})
+						assert.ErrorIs(t, err, failure)
+						return exec(ctx, "DELETE FROM users")
+					})
+				}
+			},
+			expectedStatements: []string{
+				"SAVEPOINT sp_1", "SAVEPOINT sp_2", "UPDATE users", "ROLLBACK TO SAVEPOINT sp_2",
+				"DELETE FROM users", "RELEASE SAVEPOINT sp_1",
+			},
+			expectedCommits: 1,
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			log := &txLog{}
+			var opts *sql.TxOptions
+			db := &DBExecutorMock{
+				BeginTxFunc: func(ctx context.Context, o *sql.TxOptions) (TxExecutor, error) {
+					opts = o
+					return newLoggedTx(log), nil
+				},
+			}
+
+			uow := NewPostgresUnitOfWork(db)
+			err := uow.Do(context.Background(), entity.TxOptions{Isolation: entity.TxIsolationSerializable}, tt.fn(uow))
+
+			assert.Equal(t, sql.LevelSerializable, opts.Isolation, "Expected the requested isolation level")
+			assert.ErrorIs(t, err, tt.expected)
+			assert.Equal(t, tt.expectedStatements, log.statements, "Expected statements to run in the transaction")
+			assert.Equal(t, tt.expectedCommits, log.commits)
+			assert.Equal(t, tt.expectedRollbacks, log.rollbacks)
+		})
+	}
+}
+
+func TestUnitOfWork_RetriesAbortedTransactions(t *testing.T) {
+	begins := 0
+	db := &DBExecutorMock{
+		BeginTxFunc: func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+			begins++
+			return &TxMock{}, nil
+		},
+	}
+
+	uow := NewPostgresUnitOfWork(db)
+	runs := 0
+	err := uow.Do(context.Background(), entity.TxOptions{}, func(ctx context.Context) error {
+		runs++
+		if runs == 1 {
+			return &pq.Error{Code: "40001"}
+		}
+		return nil
+	})
+	assert.NoError(t, err, "Expected the second attempt to succeed")
+	assert.Equal(t, 2, begins, "Expected a new transaction per attempt")
+
+	runs = 0
+	err = uow.Do(context.Background(), entity.TxOptions{}, func(ctx context.Context) error {
+		runs++
+		return &pq.Error{Code: "40P01"}
+	})
+	assert.Error(t, err, "Expected the error once attempts run out")
+	assert.Equal(t, maxTxAttempts, runs)
+}
+
+func TestUnitOfWork_BeginFailures(t *testing.T) {
+	for _, tt := range databaseErrorTestCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			db := &DBExecutorMock{
+				BeginTxFunc: func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+					return nil, tt.err
+				},
+			}
+
+			uow := NewPostgresUnitOfWork(db)
+			err := uow.Do(context.Background(), entity.TxOptions{}, func(ctx context.Context) error {
+				return nil
+			})
+
+			assertDatabaseError(t, tt, err)
+		})
+	}
+}
+
+func TestUnitOfWork_RepositoryTransactionsBecomeSavepoints(t *testing.T) {
+	log := &txLog{}
+	tx := newLoggedTx(log)
+	tx.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+		return newRow(t, versionCheckColumns, []driver.Value{int64(1), int64(2)})
+	}
+	db := &DBExecutorMock{
+		BeginTxFunc: func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+			return tx, nil
+		},
+	}
+
+	repo := NewPostgresUserRepository(db, logger.NewLogger(), nil)
+	err := NewPostgresUnitOfWork(db).Do(context.Background(), entity.TxOptions{}, func(ctx context.Context) error {
+		return repo.Delete(ctx, entity.User{ID: uuid.New(), Version: 1})
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "SAVEPOINT sp_1", log.statements[0], "Expected Delete to open a savepoint")
+	assert.Equal(t, "RELEASE SAVEPOINT sp_1", log.statements[len(log.statements)-1])
+	assert.Equal(t, 1, log.commits, "Expected a single commit, by the unit of work")
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+)
+
+// UserChangeNotifierMock records the changes it is asked to announce, along
+// with the connection they were to be announced on.
+type UserChangeNotifierMock struct {
+	Changes []entity.UserChange
+	Execers []Execer
+	Err     error
+}
+
+func (m *UserChangeNotifierMock) NotifyUserChanges(
+	ctx context.Context, exec Execer, changes ...entity.UserChange,
+) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Changes = append(m.Changes, changes...)
+	m.Execers = append(m.Execers, exec)
+	return nil
+}
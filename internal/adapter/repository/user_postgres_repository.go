@@ -3,106 +3,663 @@
 package repository
 
 import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/domainerr"
 	"clean-go-rest-api/internal/domain/entity"
+	"context"
 	"database/sql"
-	"log"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type TxExecutor interface {
-	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	// CopyFrom bulk loads the rows returned by next, until it returns
+	// io.EOF, into the given columns of table with COPY.
+	CopyFrom(ctx context.Context, table string, columns []string, next func() ([]interface{}, error)) (int64, error)
 	Rollback() error
 	Commit() error
 }
 
 type DBExecutor interface {
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	Query(query string, args ...interface{}) (*sql.Rows, error)
-	QueryRow(query string, args ...interface{}) *sql.Row
-	Begin() (TxExecutor, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error)
 }
 
+// Execer runs statements, in a transaction or not.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// UserChangeNotifier announces user changes to every instance of the
+// service. They are announced on exec, the connection the changes were made
+// on, so that changes made in a transaction are announced if and only if it
+// commits.
+type UserChangeNotifier interface {
+	NotifyUserChanges(ctx context.Context, exec Execer, changes ...entity.UserChange) error
+}
+
+// PostgresUserRepository runs its statements in the unit of work of the
+// context it is given, if any, and on its own otherwise. Changes made
+// outside of a unit of work are announced after they are stored, and may be
+// stored without being announced.
 type PostgresUserRepository struct {
-	db DBExecutor
+	db       DBExecutor
+	logger   logger.ILogger
+	notifier UserChangeNotifier
+}
+
+// NewPostgresUserRepository announces changes through notifier, unless it is
+// nil.
+func NewPostgresUserRepository(
+	db DBExecutor, logger logger.ILogger, notifier UserChangeNotifier,
+) *PostgresUserRepository {
+	return &PostgresUserRepository{db: db, logger: logger, notifier: notifier}
 }
 
-func NewPostgresUserRepository(db DBExecutor) *PostgresUserRepository {
-	return &PostgresUserRepository{db: db}
+func (r *PostgresUserRepository) notify(ctx context.Context, exec Execer, changes ...entity.UserChange) error {
+	if r.notifier == nil || len(changes) == 0 {
+		return nil
+	}
+	return r.notifier.NotifyUserChanges(ctx, exec, changes...)
+}
+
+// Add is a plain insert: the unique index on email, not a prior lookup,
+// decides which of two concurrent signups with the same email wins.
+func (r *PostgresUserRepository) Add(ctx context.Context, user entity.User) (entity.User, error) {
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		`INSERT INTO users (id, name, email, version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, now(), now())
+		RETURNING created_at, updated_at`,
+		user.ID, user.Name, user.Email, user.Version,
+	).Scan(&user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		r.logger.Error("Error adding user: " + err.Error())
+		err = uniqueViolationError(err, "user already exists")
+		return entity.User{}, unavailableError(err, "unable to add the user")
+	}
+	change := entity.UserChange{Type: entity.UserCreated, UserID: user.ID, Version: user.Version}
+	if err := r.notify(ctx, conn(ctx, r.db), change); err != nil {
+		return entity.User{}, err
+	}
+	return user, nil
 }
 
-func (r *PostgresUserRepository) Add(user entity.User) error {
-	_, err := r.db.Exec(
-		`INSERT INTO users (id, name, email) VALUES ($1, $2, $3)
-		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, email = EXCLUDED.email`,
-		user.ID, user.Name, user.Email,
+// AddMany inserts every user with a single statement. Users whose email is
+// already taken are skipped by ON CONFLICT and reported back; unless partial
+// is set, the whole insert is then rolled back.
+func (r *PostgresUserRepository) AddMany(
+	ctx context.Context, users []entity.User, partial bool,
+) ([]entity.User, map[uuid.UUID]bool, error) {
+	tx, err := conn(ctx, r.db).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	values := make([]string, 0, len(users))
+	args := make([]interface{}, 0, 4*len(users))
+	for _, user := range users {
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, now(), now())", n+1, n+2, n+3, n+4))
+		args = append(args, user.ID, user.Name, user.Email, user.Version)
+	}
+	rows, err := tx.QueryContext(
+		ctx,
+		`INSERT INTO users (id, name, email, version, created_at, updated_at)
+		VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT (email) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING id, created_at, updated_at`,
+		args...,
 	)
-	return err
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	type timestamps struct{ createdAt, updatedAt time.Time }
+	stored := make(map[uuid.UUID]timestamps, len(users))
+	for rows.Next() {
+		var id uuid.UUID
+		var ts timestamps
+		if err := rows.Scan(&id, &ts.createdAt, &ts.updatedAt); err != nil {
+			return nil, nil, err
+		}
+		stored[id] = ts
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	inserted := make([]entity.User, 0, len(stored))
+	conflicts := make(map[uuid.UUID]bool)
+	for _, user := range users {
+		ts, ok := stored[user.ID]
+		if !ok {
+			conflicts[user.ID] = true
+			continue
+		}
+		user.CreatedAt, user.UpdatedAt = ts.createdAt, ts.updatedAt
+		inserted = append(inserted, user)
+	}
+	if len(conflicts) > 0 && !partial {
+		return nil, conflicts, nil
+	}
+
+	changes := make([]entity.UserChange, 0, len(inserted))
+	for _, user := range inserted {
+		changes = append(changes, entity.UserChange{Type: entity.UserCreated, UserID: user.ID, Version: user.Version})
+	}
+	if err := r.notify(ctx, tx, changes...); err != nil {
+		return nil, nil, err
+	}
+	return inserted, conflicts, tx.Commit()
 }
 
-func (r *PostgresUserRepository) Delete(user entity.User) error {
-	tx, err := r.db.Begin()
+func (r *PostgresUserRepository) Delete(ctx context.Context, user entity.User) error {
+	tx, err := conn(ctx, r.db).BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	// Advisory lock by user ID to prevent concurrent modifications
-	_, err = tx.Exec("SELECT pg_advisory_xact_lock($1)", user.ID.ID())
+	_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", user.ID.ID())
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec("DELETE FROM users WHERE id = $1", user.ID)
+	// Users are only flagged as deleted here; PurgeDeleted removes the rows
+	// once the retention period is over.
+	var current, deleted sql.NullInt64
+	err = tx.QueryRowContext(
+		ctx,
+		`WITH current AS (SELECT version FROM users WHERE id = $1 AND deleted_at IS NULL),
+		deleted AS (
+			UPDATE users SET deleted_at = now(), updated_at = now(), version = version + 1
+			WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+			RETURNING version
+		)
+		SELECT (SELECT version FROM current), (SELECT version FROM deleted)`,
+		user.ID, user.Version,
+	).Scan(&current, &deleted)
 	if err != nil {
 		return err
 	}
+	if err := versionCheckError(current, deleted); err != nil {
+		return err
+	}
+	change := entity.UserChange{Type: entity.UserDeleted, UserID: user.ID, Version: deleted.Int64}
+	if err := r.notify(ctx, tx, change); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
 
-func (r *PostgresUserRepository) Update(user entity.User) error {
-	_, err := r.db.Exec(
-		`UPDATE users (id, name, email) VALUES ($1, $2, $3)
-		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, email = EXCLUDED.email`,
-		user.ID, user.Name, user.Email,
-	)
+// Update only applies the change when the stored version still matches, in
+// the same statement, so concurrent writers can never overwrite each other.
+func (r *PostgresUserRepository) Update(ctx context.Context, user entity.User) (entity.User, error) {
+	var current, updated sql.NullInt64
+	var createdAt, updatedAt sql.NullTime
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		`WITH current AS (SELECT version FROM users WHERE id = $1 AND deleted_at IS NULL),
+		updated AS (
+			UPDATE users SET name = $2, email = $3, version = version + 1, updated_at = now()
+			WHERE id = $1 AND version = $4 AND deleted_at IS NULL
+			RETURNING version, created_at, updated_at
+		)
+		SELECT (SELECT version FROM current), (SELECT version FROM updated),
+			(SELECT created_at FROM updated), (SELECT updated_at FROM updated)`,
+		user.ID, user.Name, user.Email, user.Version,
+	).Scan(&current, &updated, &createdAt, &updatedAt)
+	if err != nil {
+		return entity.User{}, uniqueViolationError(err, "email already in use by another user")
+	}
+	if err := versionCheckError(current, updated); err != nil {
+		return entity.User{}, err
+	}
+	change := entity.UserChange{Type: entity.UserUpdated, UserID: user.ID, Version: updated.Int64}
+	if err := r.notify(ctx, conn(ctx, r.db), change); err != nil {
+		return entity.User{}, err
+	}
+
+	user.Version = updated.Int64
+	user.CreatedAt = createdAt.Time
+	user.UpdatedAt = updatedAt.Time
+	return user, nil
+}
+
+// Restore brings back a deleted user, under the same version check as Update.
+func (r *PostgresUserRepository) Restore(ctx context.Context, user entity.User) (entity.User, error) {
+	var current, restored sql.NullInt64
+	var updatedAt sql.NullTime
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		`WITH current AS (SELECT version FROM users WHERE id = $1 AND deleted_at IS NOT NULL),
+		restored AS (
+			UPDATE users SET deleted_at = NULL, version = version + 1, updated_at = now()
+			WHERE id = $1 AND version = $2 AND deleted_at IS NOT NULL
+			RETURNING version, updated_at
+		)
+		SELECT (SELECT version FROM current), (SELECT version FROM restored),
+			(SELECT updated_at FROM restored)`,
+		user.ID, user.Version,
+	).Scan(&current, &restored, &updatedAt)
+	if err != nil {
+		return entity.User{}, uniqueViolationError(err, "email already in use by another user")
+	}
+	if err := versionCheckError(current, restored); err != nil {
+		return entity.User{}, err
+	}
+	change := entity.UserChange{Type: entity.UserUpdated, UserID: user.ID, Version: restored.Int64}
+	if err := r.notify(ctx, conn(ctx, r.db), change); err != nil {
+		return entity.User{}, err
+	}
+
+	user.Version = restored.Int64
+	user.UpdatedAt = updatedAt.Time
+	user.DeletedAt = time.Time{}
+	return user, nil
+}
+
+// uniqueViolation is the SQLSTATE Postgres reports when a write would break
+// a unique constraint.
+const uniqueViolation = "23505"
+
+// SQLSTATEs of a database that cannot be talked to right now.
+const (
+	connectionExceptionClass = "08"
+	adminShutdown            = "57P01"
+	crashShutdown            = "57P02"
+	cannotConnectNow         = "57P03"
+)
+
+// uniqueViolationError turns unique violations into a conflict with the given
+// message and returns any other error as is.
+func uniqueViolationError(err error, message string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return domainerr.Conflict(message)
+	}
 	return err
 }
 
-func (r *PostgresUserRepository) GetById(id uuid.UUID) (entity.User, error) {
-	var user entity.User
-	err := r.db.QueryRow(
-		"SELECT id, name, email FROM users WHERE id = $1", id,
-	).Scan(&user.ID, &user.Name, &user.Email)
+// unavailableError turns failures to reach the database into an unavailable
+// error with the given message and returns any other error as is. Aborted
+// transactions in particular are left for the unit of work to retry.
+func unavailableError(err error, message string) error {
+	if isConnectionFailure(err) {
+		return domainerr.Unavailable(message)
+	}
+	return err
+}
+
+// isConnectionFailure reports whether err means the database could not be
+// reached: a broken or refused connection, or a server shutting down.
+func isConnectionFailure(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case adminShutdown, crashShutdown, cannotConnectNow:
+		return true
+	}
+	return pqErr.Code.Class() == connectionExceptionClass
+}
+
+// versionCheckError tells a missing user apart from a stale version once a
+// conditional write has run. current is the version found before the write
+// and written is only set when the write went through.
+func versionCheckError(current, written sql.NullInt64) error {
+	if !current.Valid {
+		return domainerr.NotFound("user not found")
+	}
+	if !written.Valid {
+		return domainerr.PreconditionFailed("user was modified by another request")
+	}
+	return nil
+}
+
+func (r *PostgresUserRepository) GetById(ctx context.Context, id uuid.UUID) (entity.User, error) {
+	return r.getById(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NULL", id)
+}
+
+func (r *PostgresUserRepository) GetByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (entity.User, error) {
+	return r.getById(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
+}
+
+func (r *PostgresUserRepository) getById(ctx context.Context, query string, id uuid.UUID) (entity.User, error) {
+	user, err := scanUser(conn(ctx, r.db).QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return entity.User{}, nil
 	}
 	return user, err
 }
 
-func (r *PostgresUserRepository) Search(name string) ([]entity.User, error) {
-	rows, err := r.db.Query("SELECT id, name, email FROM users WHERE name ILIKE $1", "%"+name+"%")
+func (r *PostgresUserRepository) Search(ctx context.Context, filter entity.UserFilter) (entity.UserPage, error) {
+	query, args := buildUserSearchQuery(filter)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return entity.UserPage{}, err
 	}
 	defer rows.Close()
-	var users []entity.User
+	fuzzy := filter.Mode == entity.UserSearchFuzzy
+	page := entity.UserPage{}
+	if fuzzy {
+		page.Scores = make(map[uuid.UUID]float64)
+	}
 	for rows.Next() {
 		var user entity.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email); err != nil {
+		if fuzzy {
+			var score float64
+			user, err = scanUser(rows, &score)
+			page.Scores[user.ID] = score
+		} else {
+			user, err = scanUser(rows)
+		}
+		if err != nil {
+			return entity.UserPage{}, err
+		}
+		page.Users = append(page.Users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return entity.UserPage{}, err
+	}
+
+	if filter.Limit > 0 && len(page.Users) > filter.Limit {
+		extra := page.Users[filter.Limit]
+		delete(page.Scores, extra.ID)
+		page.Users = page.Users[:filter.Limit]
+		last := page.Users[filter.Limit-1]
+		next := entity.NewUserCursor(filter.Sort, last, page.Scores[last.ID])
+		page.Next = &next
+	}
+	return page, nil
+}
+
+// Stream relies on lib/pq reading result rows off the connection as they
+// are scanned, so memory stays flat however many users match.
+func (r *PostgresUserRepository) Stream(
+	ctx context.Context, filter entity.UserFilter, fn func(entity.User) error,
+) error {
+	filter.Limit, filter.After = 0, nil
+	query, args := buildUserSearchQuery(filter)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var extra []interface{}
+	if filter.Mode == entity.UserSearchFuzzy {
+		var score float64
+		extra = append(extra, &score)
+	}
+	for rows.Next() {
+		user, err := scanUser(rows, extra...)
+		if err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Suggest matches prefixes with LIKE so the text_pattern_ops indexes on
+// lower(name) and email can serve it as index range scans.
+// suggestUsersQuery reads name and email matches with one limited scan of
+// each prefix index, rather than a scan of both merged and sorted in full on
+// every keystroke. ~<~ is the order of text_pattern_ops, the order the
+// indexes keep. Users matched by name are left out of the email matches.
+const suggestUsersQuery = `SELECT id, name, email FROM (
+	(SELECT id, name, email, 0 AS rank, lower(name) AS key FROM users
+	WHERE deleted_at IS NULL AND lower(name) LIKE $1 ESCAPE '\'
+	ORDER BY lower(name) USING ~<~, id LIMIT $2)
+	UNION ALL
+	(SELECT id, name, email, 1 AS rank, email AS key FROM users
+	WHERE deleted_at IS NULL AND email LIKE $1 ESCAPE '\' AND lower(name) NOT LIKE $1 ESCAPE '\'
+	ORDER BY email USING ~<~, id LIMIT $2)
+) AS suggestions
+ORDER BY rank, key USING ~<~, id LIMIT $2`
+
+func (r *PostgresUserRepository) Suggest(
+	ctx context.Context, prefix string, limit int,
+) ([]entity.UserSuggestion, error) {
+	rows, err := conn(ctx, r.db).QueryContext(
+		ctx,
+		suggestUsersQuery,
+		escapeLike(prefix)+"%", limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var suggestions []entity.UserSuggestion
+	for rows.Next() {
+		var suggestion entity.UserSuggestion
+		if err := rows.Scan(&suggestion.ID, &suggestion.Name, &suggestion.Email); err != nil {
 			return nil, err
 		}
-		users = append(users, user)
+		suggestions = append(suggestions, suggestion)
 	}
-	return users, nil
+	return suggestions, rows.Err()
 }
 
-func (r *PostgresUserRepository) EmailExists(email string) bool {
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser reads a row selected with userColumns, followed by the columns
+// scanned into extra.
+func scanUser(row rowScanner, extra ...interface{}) (entity.User, error) {
+	var user entity.User
+	var deletedAt sql.NullTime
+	dest := []interface{}{
+		&user.ID, &user.Name, &user.Email, &user.Version, &user.CreatedAt, &user.UpdatedAt, &deletedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	user.DeletedAt = deletedAt.Time
+	return user, err
+}
+
+func (r *PostgresUserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
-	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 AND deleted_at IS NULL)", email,
+	).Scan(&exists)
 	if err != nil {
-		log.Println("Error checking if email exists:", err)
-		return false
+		r.logger.Error("Error checking if email exists: " + err.Error())
+		return false, unavailableError(err, "unable to check whether the email is in use")
+	}
+	return exists, nil
+}
+
+// Import copies the rows into a staging table, then merges them with a
+// single INSERT that returns the users it created. Duplicates are found
+// afterwards, as the staged rows that did not make it into users, so a
+// concurrent signup can never go unreported.
+func (r *PostgresUserRepository) Import(
+	ctx context.Context, source entity.UserSource, dryRun bool,
+) (entity.UserImportResult, error) {
+	tx, err := conn(ctx, r.db).BeginTx(ctx, nil)
+	if err != nil {
+		return entity.UserImportResult{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`CREATE TEMP TABLE users_import (
+			line integer NOT NULL,
+			id uuid NOT NULL,
+			name text NOT NULL,
+			email text NOT NULL,
+			version bigint NOT NULL
+		) ON COMMIT DROP`,
+	)
+	if err != nil {
+		return entity.UserImportResult{}, err
+	}
+
+	_, err = tx.CopyFrom(ctx, "users_import", []string{"line", "id", "name", "email", "version"},
+		func() ([]interface{}, error) {
+			row, err := source()
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{row.Line, row.User.ID, row.User.Name, row.User.Email, row.User.Version}, nil
+		},
+	)
+	if err != nil {
+		return entity.UserImportResult{}, err
+	}
+	if _, err := tx.ExecContext(ctx, "ANALYZE users_import"); err != nil {
+		return entity.UserImportResult{}, err
+	}
+
+	// The first row of each email wins; the others are reported below.
+	created, err := insertImported(ctx, tx)
+	if err != nil {
+		return entity.UserImportResult{}, err
+	}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT s.line, s.email, EXISTS (
+			SELECT 1 FROM users u
+			WHERE u.email = s.email AND u.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM users_import i WHERE i.id = u.id)
+		)
+		FROM users_import s
+		WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = s.id)
+		ORDER BY s.line`,
+	)
+	if err != nil {
+		return entity.UserImportResult{}, err
+	}
+	defer rows.Close()
+
+	result := entity.UserImportResult{Users: created}
+	for rows.Next() {
+		var duplicate entity.UserImportDuplicate
+		if err := rows.Scan(&duplicate.Line, &duplicate.Email, &duplicate.Taken); err != nil {
+			return entity.UserImportResult{}, err
+		}
+		result.Duplicates = append(result.Duplicates, duplicate)
+	}
+	if err := rows.Err(); err != nil {
+		return entity.UserImportResult{}, err
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := r.notifyImported(ctx, tx, created); err != nil {
+		return entity.UserImportResult{}, err
+	}
+	return result, tx.Commit()
+}
+
+// insertImported creates the staged users and returns them as stored.
+func insertImported(ctx context.Context, tx TxExecutor) ([]entity.User, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`INSERT INTO users (id, name, email, version, created_at, updated_at)
+		SELECT DISTINCT ON (email) id, name, email, version, now(), now()
+		FROM users_import ORDER BY email, line
+		ON CONFLICT (email) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING id, name, email, version, created_at, updated_at`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var created []entity.User
+	for rows.Next() {
+		var user entity.User
+		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Version, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, user)
+	}
+	return created, rows.Err()
+}
+
+// notifyImported announces the users created by Import.
+func (r *PostgresUserRepository) notifyImported(ctx context.Context, tx TxExecutor, created []entity.User) error {
+	changes := make([]entity.UserChange, 0, len(created))
+	for _, user := range created {
+		changes = append(changes, entity.UserChange{Type: entity.UserCreated, UserID: user.ID, Version: user.Version})
+	}
+	return r.notify(ctx, tx, changes...)
+}
+
+// queryUserChanges builds changes of type changeType from the ID and version
+// columns returned by query.
+func queryUserChanges(
+	ctx context.Context, tx TxExecutor, changeType, query string, args ...interface{},
+) ([]entity.UserChange, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []entity.UserChange
+	for rows.Next() {
+		change := entity.UserChange{Type: changeType}
+		if err := rows.Scan(&change.UserID, &change.Version); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (r *PostgresUserRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int64, error) {
+	tx, err := conn(ctx, r.db).BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	changes, err := queryUserChanges(ctx, tx, entity.UserPurged,
+		`DELETE FROM users WHERE id IN (
+			SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1 LIMIT $2
+		)
+		RETURNING id, version`,
+		before, limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.notify(ctx, tx, changes...); err != nil {
+		return 0, err
 	}
-	return exists
+	return int64(len(changes)), tx.Commit()
 }
@@ -1,12 +1,21 @@
 package repository
 
 import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/domainerr"
 	"clean-go-rest-api/internal/domain/entity"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"io"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,8 +31,9 @@ func TestUserRepository_Add(t *testing.T) {
 		{
 			testName: "Valid User Creation",
 			repoSetup: func(repo *DBExecutorMock) {
-				repo.ExecFunc = func(query string, args ...interface{}) (sql.Result, error) {
-					return nil, nil
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+					return newRow(t, []string{"created_at", "updated_at"}, []driver.Value{createdAt, createdAt})
 				}
 			},
 			input: entity.User{
@@ -36,8 +46,8 @@ func TestUserRepository_Add(t *testing.T) {
 		{
 			testName: "Error on User Creation",
 			repoSetup: func(repo *DBExecutorMock) {
-				repo.ExecFunc = func(query string, args ...interface{}) (sql.Result, error) {
-					return nil, errors.New("database error")
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newErrorRow(t, errors.New("database error"))
 				}
 			},
 			input: entity.User{
@@ -47,6 +57,20 @@ func TestUserRepository_Add(t *testing.T) {
 			},
 			expected: errors.New("database error"),
 		},
+		{
+			testName: "Email Already Taken",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newErrorRow(t, &pq.Error{Code: "23505", Constraint: "users_email_active_key"})
+				}
+			},
+			input: entity.User{
+				ID:    uuid.New(),
+				Name:  "John Doe",
+				Email: "john.doe@example.com",
+			},
+			expected: domainerr.ErrConflict,
+		},
 	}
 
 	for _, tt := range tests_scenarios {
@@ -54,49 +78,126 @@ func TestUserRepository_Add(t *testing.T) {
 			dbExecutor := &DBExecutorMock{}
 			tt.repoSetup(dbExecutor)
 
-			repo := NewPostgresUserRepository(dbExecutor)
-			err := repo.Add(tt.input)
+			repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+			user, err := repo.Add(context.Background(), tt.input)
 
 			switch tt.testName {
 			case tests_scenarios[0].testName:
 				assert.NoError(t, err, "Expected no error for valid user creation")
 				assert.Equal(t, tt.expected, err, "Expected error equal to nil")
+				assert.Equal(t, 2024, user.CreatedAt.Year(), "Expected the stored timestamps")
 			case tests_scenarios[1].testName:
 				assert.Error(t, err, "Expected an error for user creation failure")
 				assert.EqualError(t, err, tt.expected.Error(), "Expected error message to match")
+			case tests_scenarios[2].testName:
+				assert.ErrorIs(t, err, tt.expected, "Expected a unique violation to be a conflict")
 			}
 		})
 	}
 }
 
+var versionCheckColumns = []string{"current", "written"}
+
+var updateColumns = []string{"current", "updated", "created_at", "updated_at"}
+
+var userTestColumns = []string{
+	"id", "name", "email", "version", "created_at", "updated_at", "deleted_at",
+}
+
+func userTestRow(user entity.User) []driver.Value {
+	var deletedAt driver.Value
+	if user.IsDeleted() {
+		deletedAt = user.DeletedAt
+	}
+	return []driver.Value{
+		user.ID.String(), user.Name, user.Email, user.Version, user.CreatedAt, user.UpdatedAt, deletedAt,
+	}
+}
+
+func TestUserRepository_AddMany(t *testing.T) {
+	users := []entity.User{
+		{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", Version: 1},
+		{ID: uuid.New(), Name: "Mary Ann", Email: "mary@example.com", Version: 1},
+	}
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, partial := range []bool{false, true} {
+		var gotQuery string
+		var gotArgs []interface{}
+		committed := false
+		dbExecutor := &DBExecutorMock{
+			BeginTxFunc: func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+				return &TxMock{
+					QueryContextFunc: func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+						gotQuery, gotArgs = query, args
+						// Mary's email is taken, so only John comes back.
+						return newRows(t, []string{"id", "created_at", "updated_at"},
+							[]driver.Value{users[0].ID.String(), createdAt, createdAt},
+						), nil
+					},
+					CommitFunc: func() error {
+						committed = true
+						return nil
+					},
+				}, nil
+			},
+		}
+
+		repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+		inserted, conflicts, err := repo.AddMany(context.Background(), users, partial)
+
+		assert.NoError(t, err, "Expected no error for a batch insert")
+		assert.Equal(t, map[uuid.UUID]bool{users[1].ID: true}, conflicts, "Expected the skipped user")
+		if partial {
+			john := users[0]
+			john.CreatedAt, john.UpdatedAt = createdAt, createdAt
+			assert.Equal(t, []entity.User{john}, inserted, "Expected the inserted user as stored")
+		} else {
+			assert.Empty(t, inserted, "Expected nothing inserted when the batch is rolled back")
+		}
+		assert.Equal(t, partial, committed, "Expected a commit only for partial batches")
+		assert.Contains(t, gotQuery, "($1, $2, $3, $4, now(), now()), ($5, $6, $7, $8, now(), now())")
+		assert.Contains(t, gotQuery, "ON CONFLICT (email) WHERE deleted_at IS NULL DO NOTHING")
+		assert.Len(t, gotArgs, 8)
+	}
+}
+
 func TestUserRepository_Delete(t *testing.T) {
 	tests_scenarios := []testCase{
 		{
 			testName: "Valid User Deletion",
 			repoSetup: func(repo *DBExecutorMock) {
-				repo.BeginFunc = func() (TxExecutor, error) {
+				repo.BeginTxFunc = func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
 					return &TxMock{
-						ExecFunc: func(query string, args ...interface{}) (sql.Result, error) {
+						ExecContextFunc: func(
+							ctx context.Context, query string, args ...interface{},
+						) (sql.Result, error) {
 							return nil, nil
 						},
+						QueryRowContextFunc: func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+							return newRow(t, versionCheckColumns, []driver.Value{int64(2), int64(2)})
+						},
 						RollbackFunc: func() error { return nil },
 						CommitFunc:   func() error { return nil },
 					}, nil
 				}
 			},
 			input: entity.User{
-				ID:    uuid.New(),
-				Name:  "John Doe",
-				Email: "john.doe@example.com",
+				ID:      uuid.New(),
+				Name:    "John Doe",
+				Email:   "john.doe@example.com",
+				Version: 2,
 			},
 			expected: nil,
 		},
 		{
 			testName: "Error on User Deletion",
 			repoSetup: func(repo *DBExecutorMock) {
-				repo.BeginFunc = func() (TxExecutor, error) {
+				repo.BeginTxFunc = func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
 					return &TxMock{
-						ExecFunc: func(query string, args ...interface{}) (sql.Result, error) {
+						ExecContextFunc: func(
+							ctx context.Context, query string, args ...interface{},
+						) (sql.Result, error) {
 							return nil, errors.New("database error")
 						},
 						RollbackFunc: func() error { return nil },
@@ -111,14 +212,55 @@ func TestUserRepository_Delete(t *testing.T) {
 			},
 			expected: errors.New("database error"),
 		},
+		{
+			testName: "Stale Version",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.BeginTxFunc = func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+					return &TxMock{
+						QueryRowContextFunc: func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+							return newRow(t, versionCheckColumns, []driver.Value{int64(3), nil})
+						},
+						CommitFunc: func() error {
+							return errors.New("should not commit")
+						},
+					}, nil
+				}
+			},
+			input: entity.User{
+				ID:      uuid.New(),
+				Name:    "Jonas Doe",
+				Email:   "jonas.doe@example.com",
+				Version: 2,
+			},
+			expected: domainerr.ErrPreconditionFailed,
+		},
+		{
+			testName: "User Not Found",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.BeginTxFunc = func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+					return &TxMock{
+						QueryRowContextFunc: func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+							return newRow(t, versionCheckColumns, []driver.Value{nil, nil})
+						},
+					}, nil
+				}
+			},
+			input: entity.User{
+				ID:      uuid.New(),
+				Name:    "Jonas Doe",
+				Email:   "jonas.doe@example.com",
+				Version: 1,
+			},
+			expected: domainerr.ErrNotFound,
+		},
 	}
 	for _, tt := range tests_scenarios {
 		t.Run(tt.testName, func(t *testing.T) {
 			dbExecutor := &DBExecutorMock{}
 			tt.repoSetup(dbExecutor)
 
-			repo := NewPostgresUserRepository(dbExecutor)
-			err := repo.Delete(tt.input)
+			repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+			err := repo.Delete(context.Background(), tt.input)
 
 			switch tt.testName {
 			case tests_scenarios[0].testName:
@@ -127,7 +269,706 @@ func TestUserRepository_Delete(t *testing.T) {
 			case tests_scenarios[1].testName:
 				assert.Error(t, err, "Expected an error for user deletion failure")
 				assert.EqualError(t, err, tt.expected.Error(), "Expected error message to match")
+			default:
+				assert.ErrorIs(t, err, tt.expected, "Expected the version check to fail")
 			}
 		})
 	}
 }
+
+func TestUserRepository_Update(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+	tests_scenarios := []testCase{
+		{
+			testName: "Valid User Update",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newRow(t, updateColumns, []driver.Value{int64(1), int64(2), createdAt, updatedAt})
+				}
+			},
+			input: entity.User{
+				ID:      uuid.New(),
+				Name:    "John Doe",
+				Email:   "john.doe@example.com",
+				Version: 1,
+			},
+			expected: nil,
+		},
+		{
+			testName: "User Not Found",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newRow(t, updateColumns, []driver.Value{nil, nil, nil, nil})
+				}
+			},
+			input: entity.User{
+				ID:      uuid.New(),
+				Name:    "Jane Mary",
+				Email:   "jane.mary@example.com",
+				Version: 1,
+			},
+			expected: domainerr.ErrNotFound,
+		},
+		{
+			testName: "Error on User Update",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newErrorRow(t, errors.New("database error"))
+				}
+			},
+			input: entity.User{
+				ID:      uuid.New(),
+				Name:    "Jonas Doe",
+				Email:   "jonas.doe@example.com",
+				Version: 1,
+			},
+			expected: errors.New("database error"),
+		},
+		{
+			testName: "Stale Version",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newRow(t, updateColumns, []driver.Value{int64(5), nil, nil, nil})
+				}
+			},
+			input: entity.User{
+				ID:      uuid.New(),
+				Name:    "Jonas Doe",
+				Email:   "jonas.doe@example.com",
+				Version: 4,
+			},
+			expected: domainerr.ErrPreconditionFailed,
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			dbExecutor := &DBExecutorMock{}
+			tt.repoSetup(dbExecutor)
+
+			repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+			user, err := repo.Update(context.Background(), tt.input)
+
+			switch tt.testName {
+			case tests_scenarios[0].testName:
+				assert.NoError(t, err, "Expected no error for valid user update")
+				assert.Equal(t, int64(2), user.Version, "Expected the new version to be returned")
+				assert.True(t, updatedAt.Equal(user.UpdatedAt), "Expected the new update time")
+				assert.True(t, createdAt.Equal(user.CreatedAt), "Expected the creation time")
+				assert.Equal(t, tt.input.Name, user.Name)
+			case tests_scenarios[2].testName:
+				assert.Error(t, err, "Expected an error for user update failure")
+				assert.EqualError(t, err, tt.expected.Error(), "Expected error message to match")
+			default:
+				assert.ErrorIs(t, err, tt.expected, "Expected the version check to fail")
+				assert.Equal(t, entity.User{}, user)
+			}
+		})
+	}
+}
+
+func TestUserRepository_GetById(t *testing.T) {
+	columns := userTestColumns
+	tests_scenarios := []testCase{
+		{
+			testName: "User Found",
+			input: entity.User{
+				ID:        uuid.New(),
+				Name:      "John Doe",
+				Email:     "john.doe@example.com",
+				Version:   3,
+				CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				UpdatedAt: time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC),
+			},
+		},
+		{
+			testName: "User Not Found",
+			input:    entity.User{ID: uuid.New()},
+		},
+		{
+			testName: "Error on User Lookup",
+			input:    entity.User{ID: uuid.New()},
+			expected: errors.New("database error"),
+		},
+	}
+	tests_scenarios[0].repoSetup = func(repo *DBExecutorMock) {
+		user := tests_scenarios[0].input
+		repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+			return newRow(t, columns, userTestRow(user))
+		}
+	}
+	tests_scenarios[1].repoSetup = func(repo *DBExecutorMock) {
+		repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+			return newRow(t, columns)
+		}
+	}
+	tests_scenarios[2].repoSetup = func(repo *DBExecutorMock) {
+		repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+			return newErrorRow(t, errors.New("database error"))
+		}
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			dbExecutor := &DBExecutorMock{}
+			tt.repoSetup(dbExecutor)
+
+			repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+			user, err := repo.GetById(context.Background(), tt.input.ID)
+
+			switch tt.testName {
+			case tests_scenarios[0].testName:
+				assert.NoError(t, err, "Expected no error for an existing user")
+				assert.Equal(t, tt.input, user, "Expected the stored user")
+			case tests_scenarios[1].testName:
+				assert.NoError(t, err, "Expected no error for a missing user")
+				assert.Equal(t, uuid.Nil, user.ID, "Expected an empty user")
+			case tests_scenarios[2].testName:
+				assert.EqualError(t, err, tt.expected.Error(), "Expected error message to match")
+			}
+		})
+	}
+}
+
+func TestUserRepository_Search(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stored := []entity.User{
+		{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", Version: 1,
+			CreatedAt: createdAt, UpdatedAt: createdAt},
+		{ID: uuid.New(), Name: "Johnny Mary", Email: "johnny@example.com", Version: 2,
+			CreatedAt: createdAt.Add(time.Hour), UpdatedAt: createdAt.Add(2 * time.Hour)},
+	}
+
+	var gotQuery string
+	var gotArgs []interface{}
+	dbExecutor := &DBExecutorMock{
+		QueryContextFunc: func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+			gotQuery, gotArgs = query, args
+			return newRows(t, userTestColumns, userTestRow(stored[0]), userTestRow(stored[1])), nil
+		},
+	}
+
+	repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+	filter := entity.UserFilter{
+		Name:         "john",
+		CreatedAfter: createdAt.Add(-time.Hour),
+		Sort:         entity.UserSort{Field: entity.UserSortByUpdatedAt, Descending: true},
+	}
+	page, err := repo.Search(context.Background(), filter)
+
+	assert.NoError(t, err, "Expected no error for a valid search")
+	assert.Equal(t, stored, page.Users, "Expected every scanned user")
+	assert.Nil(t, page.Next, "Expected no next page without a limit")
+	assert.Contains(t, gotQuery, `WHERE deleted_at IS NULL AND name ILIKE $1 ESCAPE '\' AND created_at > $2`)
+	assert.Contains(t, gotQuery, "ORDER BY updated_at DESC, id DESC")
+	assert.Equal(t, []interface{}{"%john%", filter.CreatedAfter}, gotArgs)
+
+	filter.Limit = 1
+	page, err = repo.Search(context.Background(), filter)
+
+	assert.NoError(t, err, "Expected no error for a limited search")
+	assert.Equal(t, stored[:1], page.Users, "Expected the extra row to be left out")
+	assert.Equal(t, &entity.UserCursor{Sort: filter.Sort, Value: stored[0].UpdatedAt, ID: stored[0].ID},
+		page.Next, "Expected a cursor after the last user of the page")
+	assert.Contains(t, gotQuery, "LIMIT $3")
+	assert.Equal(t, 2, gotArgs[2], "Expected one row more than the limit")
+}
+
+func TestUserRepository_SearchFuzzy(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stored := []entity.User{
+		{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", Version: 1,
+			CreatedAt: createdAt, UpdatedAt: createdAt},
+		{ID: uuid.New(), Name: "Jon Snow", Email: "jon@example.com", Version: 1,
+			CreatedAt: createdAt, UpdatedAt: createdAt},
+	}
+	scoredColumns := append(append([]string{}, userTestColumns...), "score")
+	dbExecutor := &DBExecutorMock{
+		QueryContextFunc: func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+			return newRows(t, scoredColumns,
+				append(userTestRow(stored[0]), 0.8),
+				append(userTestRow(stored[1]), 0.5),
+			), nil
+		},
+	}
+
+	repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+	filter := entity.UserFilter{
+		Mode: entity.UserSearchFuzzy, Query: "john", Sort: entity.RelevanceUserSort, Limit: 1,
+	}
+	page, err := repo.Search(context.Background(), filter)
+
+	assert.NoError(t, err, "Expected no error for a fuzzy search")
+	assert.Equal(t, stored[:1], page.Users, "Expected the best match only")
+	assert.Equal(t, map[uuid.UUID]float64{stored[0].ID: 0.8}, page.Scores,
+		"Expected the scores of the page only")
+	assert.Equal(t, &entity.UserCursor{Sort: filter.Sort, Value: 0.8, ID: stored[0].ID}, page.Next,
+		"Expected a cursor after the last score")
+}
+
+func TestUserRepository_Stream(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stored := []entity.User{
+		{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", Version: 1,
+			CreatedAt: createdAt, UpdatedAt: createdAt},
+		{ID: uuid.New(), Name: "Mary Ann", Email: "mary@example.com", Version: 1,
+			CreatedAt: createdAt, UpdatedAt: createdAt},
+	}
+	var gotQuery string
+	dbExecutor := &DBExecutorMock{
+		QueryContextFunc: func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+			gotQuery = query
+			return newRows(t, userTestColumns, userTestRow(stored[0]), userTestRow(stored[1])), nil
+		},
+	}
+
+	repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+	var streamed []entity.User
+	filter := entity.UserFilter{Limit: 1, After: &entity.UserCursor{Value: createdAt, ID: stored[0].ID}}
+	err := repo.Stream(context.Background(), filter, func(user entity.User) error {
+		streamed = append(streamed, user)
+		return nil
+	})
+
+	assert.NoError(t, err, "Expected no error for a stream")
+	assert.Equal(t, stored, streamed, "Expected every user to be handed over")
+	assert.NotContains(t, gotQuery, "LIMIT", "Expected the whole result set")
+	assert.NotContains(t, gotQuery, "(created_at, id)", "Expected no cursor condition")
+}
+
+type userSearchQueryTestCase struct {
+	testName string
+	filter   entity.UserFilter
+	query    string
+	args     []interface{}
+}
+
+func TestBuildUserSearchQuery(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := after.AddDate(0, 1, 0)
+	cursorID := uuid.New()
+	tests_scenarios := []userSearchQueryTestCase{
+		{
+			testName: "No Criteria",
+			filter:   entity.UserFilter{},
+			query: "SELECT " + userColumns + " FROM users WHERE deleted_at IS NULL" +
+				" ORDER BY created_at ASC, id ASC",
+		},
+		{
+			testName: "Including Deleted",
+			filter:   entity.UserFilter{IncludeDeleted: true},
+			query:    "SELECT " + userColumns + " FROM users ORDER BY created_at ASC, id ASC",
+		},
+		{
+			testName: "Time Ranges",
+			filter: entity.UserFilter{
+				CreatedAfter:  after,
+				CreatedBefore: before,
+				UpdatedAfter:  after,
+				UpdatedBefore: before,
+				Sort:          entity.UserSort{Field: entity.UserSortByCreatedAt, Descending: true},
+			},
+			query: "SELECT " + userColumns + " FROM users WHERE deleted_at IS NULL" +
+				" AND created_at > $1 AND created_at < $2 AND updated_at > $3 AND updated_at < $4" +
+				" ORDER BY created_at DESC, id DESC",
+			args: []interface{}{after, before, after, before},
+		},
+		{
+			testName: "Next Page",
+			filter: entity.UserFilter{
+				Sort:  entity.UserSort{Field: entity.UserSortByUpdatedAt, Descending: true},
+				Limit: 20,
+				After: &entity.UserCursor{Value: after, ID: cursorID},
+			},
+			query: "SELECT " + userColumns + " FROM users WHERE deleted_at IS NULL" +
+				" AND (updated_at, id) < ($1, $2) ORDER BY updated_at DESC, id DESC LIMIT $3",
+			args: []interface{}{after, cursorID, 21},
+		},
+		{
+			testName: "Email Criteria Sorted By Name",
+			filter: entity.UserFilter{
+				Email:       "john@example.com",
+				EmailDomain: "example.com",
+				Sort:        entity.UserSort{Field: entity.UserSortByName},
+				After:       &entity.UserCursor{Value: "John", ID: cursorID},
+			},
+			query: "SELECT " + userColumns + " FROM users WHERE deleted_at IS NULL" +
+				" AND email = $1 AND split_part(email, '@', 2) = $2 AND (name, id) > ($3, $4)" +
+				" ORDER BY name ASC, id ASC",
+			args: []interface{}{"john@example.com", "example.com", "John", cursorID},
+		},
+		{
+			testName: "Wildcards In Name",
+			filter:   entity.UserFilter{Name: `50%_off\`},
+			query: "SELECT " + userColumns + " FROM users WHERE deleted_at IS NULL" +
+				` AND name ILIKE $1 ESCAPE '\' ORDER BY created_at ASC, id ASC`,
+			args: []interface{}{`%50\%\_off\\%`},
+		},
+		{
+			testName: "Fuzzy By Relevance",
+			filter: entity.UserFilter{
+				Mode:  entity.UserSearchFuzzy,
+				Query: "jon",
+				Sort:  entity.RelevanceUserSort,
+				After: &entity.UserCursor{Value: 0.5, ID: cursorID},
+			},
+			query: "SELECT " + userColumns +
+				", greatest(similarity(name, $1), similarity(email, $1)) AS score" +
+				" FROM users WHERE deleted_at IS NULL AND (name % $1 OR email % $1)" +
+				" AND (greatest(similarity(name, $1), similarity(email, $1)), id) < ($2, $3)" +
+				" ORDER BY score DESC, id DESC",
+			args: []interface{}{"jon", 0.5, cursorID},
+		},
+		{
+			testName: "Unknown Sort Field",
+			filter:   entity.UserFilter{Sort: entity.UserSort{Field: "name; DROP TABLE users"}},
+			query: "SELECT " + userColumns + " FROM users WHERE deleted_at IS NULL" +
+				" ORDER BY created_at ASC, id ASC",
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			query, args := buildUserSearchQuery(tt.filter)
+			assert.Equal(t, tt.query, query)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func TestUserRepository_Restore(t *testing.T) {
+	updatedAt := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	restoreColumns := []string{"current", "restored", "updated_at"}
+	tests_scenarios := []testCase{
+		{
+			testName: "Valid User Restore",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newRow(t, restoreColumns, []driver.Value{int64(2), int64(3), updatedAt})
+				}
+			},
+			input: entity.User{
+				ID:        uuid.New(),
+				Name:      "John Doe",
+				Email:     "john.doe@example.com",
+				Version:   2,
+				DeletedAt: updatedAt.Add(-time.Hour),
+			},
+		},
+		{
+			testName: "User Not Deleted",
+			repoSetup: func(repo *DBExecutorMock) {
+				repo.QueryRowContextFunc = func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newRow(t, restoreColumns, []driver.Value{nil, nil, nil})
+				}
+			},
+			input:    entity.User{ID: uuid.New(), Version: 1},
+			expected: domainerr.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			dbExecutor := &DBExecutorMock{}
+			tt.repoSetup(dbExecutor)
+
+			repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+			user, err := repo.Restore(context.Background(), tt.input)
+
+			switch tt.testName {
+			case tests_scenarios[0].testName:
+				assert.NoError(t, err, "Expected no error for a valid restore")
+				assert.False(t, user.IsDeleted(), "Expected the user to be restored")
+				assert.Equal(t, int64(3), user.Version, "Expected the new version")
+				assert.True(t, updatedAt.Equal(user.UpdatedAt), "Expected the new update time")
+			case tests_scenarios[1].testName:
+				assert.ErrorIs(t, err, tt.expected, "Expected not found for an active user")
+			}
+		})
+	}
+}
+
+func TestUserRepository_PurgeDeleted(t *testing.T) {
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	purgedID := uuid.New()
+	var gotArgs []interface{}
+	committed := false
+	tx := &TxMock{
+		QueryContextFunc: func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+			gotArgs = args
+			return newRows(t, []string{"id", "version"}, []driver.Value{purgedID.String(), int64(4)}), nil
+		},
+		CommitFunc: func() error {
+			committed = true
+			return nil
+		},
+	}
+	dbExecutor := &DBExecutorMock{
+		BeginTxFunc: func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+			return tx, nil
+		},
+	}
+	notifier := &UserChangeNotifierMock{}
+
+	repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), notifier)
+	purged, err := repo.PurgeDeleted(context.Background(), before, 100)
+
+	assert.NoError(t, err, "Expected no error for a purge")
+	assert.Equal(t, int64(1), purged, "Expected the number of purged users")
+	assert.Equal(t, []interface{}{before, 100}, gotArgs)
+	assert.True(t, committed, "Expected the purge to be committed")
+	assert.Equal(t, []entity.UserChange{{Type: entity.UserPurged, UserID: purgedID, Version: 4}}, notifier.Changes,
+		"Expected the purged users to be announced",
+	)
+	assert.Equal(t, []Execer{tx}, notifier.Execers, "Expected the purge to be announced in its transaction")
+}
+
+type notifyTestCase struct {
+	testName    string
+	notifierErr error
+}
+
+func TestUserRepository_NotifyUpdate(t *testing.T) {
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john.doe@example.com", Version: 2}
+	tests_scenarios := []notifyTestCase{
+		{testName: "Update Announced"},
+		{testName: "Announcement Failure", notifierErr: errors.New("connection reset")},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			dbExecutor := &DBExecutorMock{
+				QueryRowContextFunc: func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newRow(t, []string{"current", "updated", "created_at", "updated_at"},
+						[]driver.Value{int64(2), int64(3), time.Now(), time.Now()},
+					)
+				},
+			}
+			notifier := &UserChangeNotifierMock{Err: tt.notifierErr}
+
+			repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), notifier)
+			_, err := repo.Update(context.Background(), user)
+
+			switch tt.testName {
+			case tests_scenarios[0].testName:
+				assert.NoError(t, err, "Expected no error for a valid update")
+				assert.Equal(t, []entity.UserChange{{Type: entity.UserUpdated, UserID: user.ID, Version: 3}},
+					notifier.Changes, "Expected the update to be announced with the new version",
+				)
+			case tests_scenarios[1].testName:
+				assert.ErrorIs(t, err, tt.notifierErr, "Expected the announcement failure to fail the update")
+			}
+		})
+	}
+}
+
+func TestUserRepository_Suggest(t *testing.T) {
+	stored := entity.UserSuggestion{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	var gotQuery string
+	var gotArgs []interface{}
+	dbExecutor := &DBExecutorMock{
+		QueryContextFunc: func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+			gotQuery, gotArgs = query, args
+			return newRows(t, []string{"id", "name", "email"},
+				[]driver.Value{stored.ID.String(), stored.Name, stored.Email},
+			), nil
+		},
+	}
+
+	repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+	suggestions, err := repo.Suggest(context.Background(), "jo_", 10)
+
+	assert.NoError(t, err, "Expected no error for suggestions")
+	assert.Equal(t, []entity.UserSuggestion{stored}, suggestions)
+	assert.Equal(t, []interface{}{`jo\_%`, 10}, gotArgs, "Expected an escaped prefix pattern")
+	assert.Contains(t, gotQuery, "ORDER BY lower(name) USING ~<~, id LIMIT $2",
+		"Expected the name matches to be read in the order of the name prefix index")
+	assert.Contains(t, gotQuery, "ORDER BY email USING ~<~, id LIMIT $2",
+		"Expected the email matches to be read in the order of the email prefix index")
+	assert.Contains(t, gotQuery, "UNION ALL", "Expected the two limited scans to be merged")
+	assert.NotContains(t, gotQuery, " OR ", "Expected no condition the indexes cannot serve")
+}
+
+// databaseErrorTestCase is a failure of the database and whether it means
+// the database could not be reached.
+type databaseErrorTestCase struct {
+	testName    string
+	err         error
+	unavailable bool
+}
+
+var databaseErrorTestCases = []databaseErrorTestCase{
+	{testName: "Bad Connection", err: driver.ErrBadConn, unavailable: true},
+	{
+		testName:    "Connection Refused",
+		err:         &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+		unavailable: true,
+	},
+	{testName: "Connection Failure", err: &pq.Error{Code: "08006"}, unavailable: true},
+	{testName: "Server Shutting Down", err: &pq.Error{Code: "57P01"}, unavailable: true},
+	{testName: "Serialization Failure", err: &pq.Error{Code: "40001"}, unavailable: false},
+	{testName: "Deadlock", err: &pq.Error{Code: "40P01"}, unavailable: false},
+	{testName: "Other Failure", err: errors.New("database error"), unavailable: false},
+}
+
+// assertDatabaseError checks that only failures to reach the database are
+// reported unavailable, and that any other error is returned unchanged.
+func assertDatabaseError(t *testing.T, tt databaseErrorTestCase, err error) {
+	if tt.unavailable {
+		assert.ErrorIs(t, err, domainerr.ErrUnavailable, "Expected the database to be reported unavailable")
+		return
+	}
+	assert.Equal(t, tt.err, err, "Expected the error to be returned unchanged")
+}
+
+func TestUserRepository_EmailExists(t *testing.T) {
+	for _, tt := range databaseErrorTestCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			dbExecutor := &DBExecutorMock{
+				QueryRowContextFunc: func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newErrorRow(t, tt.err)
+				},
+			}
+
+			repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+			exists, err := repo.EmailExists(context.Background(), "john@example.com")
+
+			assertDatabaseError(t, tt, err)
+			assert.False(t, exists)
+		})
+	}
+}
+
+func TestUserRepository_AddFailures(t *testing.T) {
+	for _, tt := range databaseErrorTestCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			dbExecutor := &DBExecutorMock{
+				QueryRowContextFunc: func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return newErrorRow(t, tt.err)
+				},
+			}
+
+			repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+			_, err := repo.Add(context.Background(), entity.User{
+				ID: uuid.New(), Name: "John Doe", Email: "john@example.com",
+			})
+
+			assertDatabaseError(t, tt, err)
+		})
+	}
+}
+
+func TestUserRepository_Import(t *testing.T) {
+	rows := []entity.UserImportRow{
+		{Line: 2, User: entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", Version: 1}},
+		{Line: 3, User: entity.User{ID: uuid.New(), Name: "John Twin", Email: "john@example.com", Version: 1}},
+	}
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, dryRun := range []bool{false, true} {
+		var copied [][]interface{}
+		var statements []string
+		committed := false
+		dbExecutor := &DBExecutorMock{
+			BeginTxFunc: func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+				return &TxMock{
+					ExecContextFunc: func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+						statements = append(statements, query)
+						return SQLResultMock{RowsAffectedValue: 1}, nil
+					},
+					CopyFromFunc: func(
+						ctx context.Context, table string, columns []string, next func() ([]interface{}, error),
+					) (int64, error) {
+						assert.Equal(t, "users_import", table)
+						for {
+							row, err := next()
+							if err != nil {
+								return int64(len(copied)), nil
+							}
+							copied = append(copied, row)
+						}
+					},
+					QueryContextFunc: func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+						statements = append(statements, query)
+						if strings.HasPrefix(query, "INSERT INTO users") {
+							return newRows(t, []string{"id", "name", "email", "version", "created_at", "updated_at"},
+								[]driver.Value{
+									rows[0].User.ID.String(), "John Doe", "john@example.com", int64(1),
+									createdAt, createdAt,
+								},
+							), nil
+						}
+						return newRows(t, []string{"line", "email", "taken"},
+							[]driver.Value{int64(3), "john@example.com", false},
+						), nil
+					},
+					CommitFunc: func() error {
+						committed = true
+						return nil
+					},
+				}, nil
+			},
+		}
+
+		next := 0
+		source := func() (entity.UserImportRow, error) {
+			if next == len(rows) {
+				return entity.UserImportRow{}, io.EOF
+			}
+			next++
+			return rows[next-1], nil
+		}
+
+		repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+		result, err := repo.Import(context.Background(), source, dryRun)
+
+		assert.NoError(t, err, "Expected no error for an import")
+		assert.Len(t, copied, 2, "Expected every row to be copied")
+		assert.Equal(t, []interface{}{2, rows[0].User.ID, "John Doe", "john@example.com", int64(1)}, copied[0])
+		assert.Equal(t, entity.UserImportResult{
+			Users: []entity.User{{
+				ID: rows[0].User.ID, Name: "John Doe", Email: "john@example.com", Version: 1,
+				CreatedAt: createdAt, UpdatedAt: createdAt,
+			}},
+			Duplicates: []entity.UserImportDuplicate{{Line: 3, Email: "john@example.com"}},
+		}, result, "Expected the created users to be returned as stored")
+		assert.Equal(t, !dryRun, committed, "Expected a dry run to roll back")
+		for _, statement := range statements {
+			assert.NotContains(t, statement, "outbox", "Expected the events to be left to the outbox repository")
+		}
+	}
+}
+
+func TestUserRepository_CanceledContext(t *testing.T) {
+	execCalled := false
+	dbExecutor := &DBExecutorMock{
+		ExecContextFunc: func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			execCalled = true
+			return nil, nil
+		},
+		QueryRowContextFunc: func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+			if err := ctx.Err(); err != nil {
+				return newErrorRow(t, err)
+			}
+			execCalled = true
+			return newRow(t, []string{"created_at", "updated_at"})
+		},
+		BeginTxFunc: func(ctx context.Context, opts *sql.TxOptions) (TxExecutor, error) {
+			return &TxMock{}, nil
+		},
+	}
+	repo := NewPostgresUserRepository(dbExecutor, logger.NewLogger(), nil)
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john.doe@example.com"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.Add(ctx, user)
+	assert.ErrorIs(t, err, context.Canceled, "Expected add to be canceled")
+
+	err = repo.Delete(ctx, user)
+	assert.ErrorIs(t, err, context.Canceled, "Expected delete to be canceled")
+
+	assert.False(t, execCalled, "Expected no statement to reach the database")
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const userColumns = "id, name, email, version, created_at, updated_at, deleted_at"
+
+// userSortColumns maps the sortable fields to their columns. Only columns
+// listed here ever reach the ORDER BY clause.
+var userSortColumns = map[entity.UserSortField]string{
+	entity.UserSortByCreatedAt: "created_at",
+	entity.UserSortByUpdatedAt: "updated_at",
+	entity.UserSortByName:      "name",
+	entity.UserSortByEmail:     "email",
+}
+
+// userSearchQuery accumulates the conditions of a user search along with
+// their positional arguments.
+type userSearchQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+// where adds a condition written with one "?" placeholder per arg.
+func (q *userSearchQuery) where(condition string, args ...interface{}) {
+	for _, arg := range args {
+		q.args = append(q.args, arg)
+		condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(q.args)), 1)
+	}
+	q.conditions = append(q.conditions, condition)
+}
+
+func (q *userSearchQuery) whereTime(condition string, t time.Time) {
+	if !t.IsZero() {
+		q.where(condition, t)
+	}
+}
+
+// buildUserSearchQuery selects the page of users described by filter. One
+// row more than the limit is asked for, to know whether another page follows.
+func buildUserSearchQuery(filter entity.UserFilter) (string, []interface{}) {
+	q := &userSearchQuery{}
+	if !filter.IncludeDeleted {
+		q.conditions = append(q.conditions, "deleted_at IS NULL")
+	}
+
+	columns := userColumns
+	var score string
+	if filter.Mode == entity.UserSearchFuzzy {
+		q.args = append(q.args, filter.Query)
+		term := fmt.Sprintf("$%d", len(q.args))
+		// % is the pg_trgm similarity operator, served by the trigram indexes.
+		q.conditions = append(q.conditions, fmt.Sprintf("(name %% %s OR email %% %s)", term, term))
+		score = fmt.Sprintf("greatest(similarity(name, %s), similarity(email, %s))", term, term)
+		columns += ", " + score + " AS score"
+	}
+
+	if filter.Name != "" {
+		q.where(`name ILIKE ? ESCAPE '\'`, "%"+escapeLike(filter.Name)+"%")
+	}
+	if filter.Email != "" {
+		q.where("email = ?", filter.Email)
+	}
+	if filter.EmailDomain != "" {
+		q.where("split_part(email, '@', 2) = ?", filter.EmailDomain)
+	}
+	q.whereTime("created_at > ?", filter.CreatedAfter)
+	q.whereTime("created_at < ?", filter.CreatedBefore)
+	q.whereTime("updated_at > ?", filter.UpdatedAfter)
+	q.whereTime("updated_at < ?", filter.UpdatedBefore)
+
+	column, ok := userSortColumns[filter.Sort.Field]
+	if !ok {
+		column = userSortColumns[entity.DefaultUserSort.Field]
+	}
+	key := column
+	if filter.Sort.Field == entity.UserSortByRelevance && score != "" {
+		column, key = "score", score
+	}
+	direction, comparison := "ASC", ">"
+	if filter.Sort.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	// The row comparison matches the (column, id) indexes, so each page is
+	// an index range scan no matter how deep into the results it is. Pages
+	// by relevance have to rank every match anyway.
+	if filter.After != nil {
+		q.where(fmt.Sprintf("(%s, id) %s (?, ?)", key, comparison), filter.After.Value, filter.After.ID)
+	}
+
+	var sql strings.Builder
+	sql.WriteString("SELECT " + columns + " FROM users")
+	if len(q.conditions) > 0 {
+		sql.WriteString(" WHERE " + strings.Join(q.conditions, " AND "))
+	}
+
+	// id breaks ties so the order is stable across identical sort keys.
+	fmt.Fprintf(&sql, " ORDER BY %s %s, id %s", column, direction, direction)
+	if filter.Limit > 0 {
+		q.args = append(q.args, filter.Limit+1)
+		fmt.Fprintf(&sql, " LIMIT $%d", len(q.args))
+	}
+
+	return sql.String(), q.args
+}
+
+// escapeLike makes LIKE wildcards in s match themselves.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
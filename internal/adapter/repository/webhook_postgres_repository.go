@@ -0,0 +1,202 @@
+// Clean Architecture - Interface Adapter Layer
+// WebhookRepository implementation for PostgreSQL
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, body, status, attempts,
+	next_attempt_at, last_status_code, last_error, created_at, updated_at, delivered_at`
+
+type PostgresWebhookRepository struct {
+	db DBExecutor
+}
+
+func NewPostgresWebhookRepository(db DBExecutor) *PostgresWebhookRepository {
+	return &PostgresWebhookRepository{db: db}
+}
+
+func (r *PostgresWebhookRepository) AddSubscription(
+	ctx context.Context, subscription entity.WebhookSubscription,
+) (entity.WebhookSubscription, error) {
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		`INSERT INTO webhook_subscriptions (id, url, event_types, secret, created_at)
+		VALUES ($1, $2, $3, $4, now())
+		RETURNING created_at`,
+		subscription.ID, subscription.URL, pq.Array(subscription.EventTypes), subscription.Secret,
+	).Scan(&subscription.CreatedAt)
+	if err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	return subscription, nil
+}
+
+func (r *PostgresWebhookRepository) GetSubscription(
+	ctx context.Context, id uuid.UUID,
+) (entity.WebhookSubscription, error) {
+	subscription, err := scanWebhookSubscription(conn(ctx, r.db).QueryRowContext(
+		ctx,
+		"SELECT id, url, event_types, secret, created_at FROM webhook_subscriptions WHERE id = $1",
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return entity.WebhookSubscription{}, nil
+	}
+	return subscription, err
+}
+
+func (r *PostgresWebhookRepository) SubscriptionsFor(
+	ctx context.Context, eventType string,
+) ([]entity.WebhookSubscription, error) {
+	rows, err := conn(ctx, r.db).QueryContext(
+		ctx,
+		`SELECT id, url, event_types, secret, created_at FROM webhook_subscriptions
+		WHERE event_types @> ARRAY[$1]::text[]`,
+		eventType,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []entity.WebhookSubscription
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+func scanWebhookSubscription(row rowScanner) (entity.WebhookSubscription, error) {
+	var subscription entity.WebhookSubscription
+	err := row.Scan(
+		&subscription.ID, &subscription.URL, pq.Array(&subscription.EventTypes),
+		&subscription.Secret, &subscription.CreatedAt,
+	)
+	return subscription, err
+}
+
+func (r *PostgresWebhookRepository) AddDeliveries(ctx context.Context, deliveries ...entity.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(deliveries))
+	args := make([]interface{}, 0, 6*len(deliveries))
+	for _, delivery := range deliveries {
+		n := len(args)
+		values = append(values, fmt.Sprintf(
+			"($%d, $%d, $%d, $%d, $%d, 'pending', $%d, now(), now())", n+1, n+2, n+3, n+4, n+5, n+6,
+		))
+		args = append(args,
+			delivery.ID, delivery.SubscriptionID, delivery.EventID, delivery.EventType,
+			string(delivery.Body), delivery.NextAttemptAt,
+		)
+	}
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		`INSERT INTO webhook_deliveries
+		(id, subscription_id, event_id, event_type, body, status, next_attempt_at, created_at, updated_at)
+		VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		args...,
+	)
+	return err
+}
+
+// ClaimDue leases the deliveries in a single statement: unlike a lock, the
+// lease outlives it, so deliveries are never held locked while waiting on a
+// subscriber.
+func (r *PostgresWebhookRepository) ClaimDue(
+	ctx context.Context, now time.Time, lease time.Duration, limit int,
+) ([]entity.WebhookDelivery, error) {
+	rows, err := conn(ctx, r.db).QueryContext(
+		ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+webhookDeliveryColumns,
+		now, now.Add(lease), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+func (r *PostgresWebhookRepository) RecordAttempt(ctx context.Context, delivery entity.WebhookDelivery) error {
+	var deliveredAt sql.NullTime
+	if !delivery.DeliveredAt.IsZero() {
+		deliveredAt = sql.NullTime{Time: delivery.DeliveredAt, Valid: true}
+	}
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		`UPDATE webhook_deliveries SET status = $2, attempts = $3, next_attempt_at = $4,
+			last_status_code = NULLIF($5, 0), last_error = NULLIF($6, ''), delivered_at = $7, updated_at = now()
+		WHERE id = $1`,
+		delivery.ID, string(delivery.Status), delivery.Attempts, delivery.NextAttemptAt,
+		delivery.LastStatusCode, delivery.LastError, deliveredAt,
+	)
+	return err
+}
+
+func (r *PostgresWebhookRepository) Deliveries(
+	ctx context.Context, subscriptionID uuid.UUID, limit int,
+) ([]entity.WebhookDelivery, error) {
+	rows, err := conn(ctx, r.db).QueryContext(
+		ctx,
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		WHERE subscription_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`,
+		subscriptionID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]entity.WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []entity.WebhookDelivery
+	for rows.Next() {
+		var delivery entity.WebhookDelivery
+		var status string
+		var statusCode sql.NullInt64
+		var lastError sql.NullString
+		var deliveredAt sql.NullTime
+		err := rows.Scan(
+			&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &delivery.EventType, &delivery.Body,
+			&status, &delivery.Attempts, &delivery.NextAttemptAt, &statusCode, &lastError,
+			&delivery.CreatedAt, &delivery.UpdatedAt, &deliveredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Status = entity.WebhookDeliveryStatus(status)
+		delivery.LastStatusCode = int(statusCode.Int64)
+		delivery.LastError = lastError.String
+		delivery.DeliveredAt = deliveredAt.Time
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
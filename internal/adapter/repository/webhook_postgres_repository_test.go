@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type getSubscriptionTestCase struct {
+	testName string
+	row      func(t *testing.T) *sql.Row
+	expected entity.WebhookSubscription
+}
+
+func TestWebhookRepository_GetSubscription(t *testing.T) {
+	stored := entity.WebhookSubscription{
+		ID:         uuid.New(),
+		URL:        "https://example.com/hooks",
+		EventTypes: []string{entity.UserCreated, entity.UserDeleted},
+		Secret:     "0123456789abcdef",
+		CreatedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	tests_scenarios := []getSubscriptionTestCase{
+		{
+			testName: "Found",
+			row: func(t *testing.T) *sql.Row {
+				return newRow(t, []string{"id", "url", "event_types", "secret", "created_at"},
+					[]driver.Value{
+						stored.ID.String(), stored.URL, "{user.created,user.deleted}", stored.Secret, stored.CreatedAt,
+					},
+				)
+			},
+			expected: stored,
+		},
+		{
+			testName: "Not Found",
+			row: func(t *testing.T) *sql.Row {
+				return newErrorRow(t, sql.ErrNoRows)
+			},
+			expected: entity.WebhookSubscription{},
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			dbExecutor := &DBExecutorMock{
+				QueryRowContextFunc: func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+					return tt.row(t)
+				},
+			}
+
+			repo := NewPostgresWebhookRepository(dbExecutor)
+			subscription, err := repo.GetSubscription(context.Background(), stored.ID)
+
+			assert.NoError(t, err, "Expected no error for a lookup")
+			assert.Equal(t, tt.expected, subscription)
+		})
+	}
+}
+
+func TestWebhookRepository_AddDeliveries(t *testing.T) {
+	deliveries := []entity.WebhookDelivery{
+		{ID: uuid.New(), SubscriptionID: uuid.New(), EventID: uuid.New(), Body: []byte(`{"id":"1"}`)},
+		{ID: uuid.New(), SubscriptionID: uuid.New(), EventID: uuid.New(), Body: []byte(`{"id":"2"}`)},
+	}
+	var gotQuery string
+	var gotArgs []interface{}
+	dbExecutor := &DBExecutorMock{
+		ExecContextFunc: func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			gotQuery, gotArgs = query, args
+			return SQLResultMock{RowsAffectedValue: 2}, nil
+		},
+	}
+
+	repo := NewPostgresWebhookRepository(dbExecutor)
+	err := repo.AddDeliveries(context.Background(), deliveries...)
+
+	assert.NoError(t, err, "Expected no error for an insert")
+	assert.Contains(t, gotQuery, "($7, $8, $9, $10, $11, 'pending', $12, now(), now())")
+	assert.Contains(t, gotQuery, "ON CONFLICT (subscription_id, event_id) DO NOTHING",
+		"Expected deliveries already queued to be skipped",
+	)
+	assert.Equal(t, `{"id":"1"}`, gotArgs[4], "Expected the body to be sent as text")
+	assert.Len(t, gotArgs, 12)
+}
+
+func TestWebhookRepository_ClaimDue(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stored := entity.WebhookDelivery{
+		ID:             uuid.New(),
+		SubscriptionID: uuid.New(),
+		EventID:        uuid.New(),
+		EventType:      entity.UserUpdated,
+		Body:           []byte(`{"id":"1"}`),
+		Status:         entity.WebhookDeliveryPending,
+		Attempts:       1,
+		NextAttemptAt:  now.Add(time.Minute),
+		LastError:      "subscriber responded with 500 Internal Server Error",
+		LastStatusCode: 500,
+		CreatedAt:      now.Add(-time.Hour),
+		UpdatedAt:      now.Add(-time.Minute),
+	}
+	var gotQuery string
+	var gotArgs []interface{}
+	dbExecutor := &DBExecutorMock{
+		QueryContextFunc: func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+			gotQuery, gotArgs = query, args
+			return newRows(t,
+				[]string{
+					"id", "subscription_id", "event_id", "event_type", "body", "status", "attempts",
+					"next_attempt_at", "last_status_code", "last_error", "created_at", "updated_at", "delivered_at",
+				},
+				[]driver.Value{
+					stored.ID.String(), stored.SubscriptionID.String(), stored.EventID.String(), stored.EventType,
+					stored.Body, string(stored.Status), int64(stored.Attempts), stored.NextAttemptAt,
+					int64(stored.LastStatusCode), stored.LastError, stored.CreatedAt, stored.UpdatedAt, nil,
+				},
+			), nil
+		},
+	}
+
+	repo := NewPostgresWebhookRepository(dbExecutor)
+	deliveries, err := repo.ClaimDue(context.Background(), now, time.Minute, 10)
+
+	assert.NoError(t, err, "Expected no error for a claim")
+	assert.Equal(t, []entity.WebhookDelivery{stored}, deliveries)
+	assert.Contains(t, gotQuery, "FOR UPDATE SKIP LOCKED", "Expected claimed deliveries to be skipped by others")
+	assert.Equal(t, []interface{}{now, now.Add(time.Minute), 10}, gotArgs,
+		"Expected the lease to end a minute from now",
+	)
+}
@@ -0,0 +1,81 @@
+// Clean Architecture - Interface Adapter Layer
+// WebhookSender that posts signed deliveries over HTTP
+package webhook
+
+import (
+	"bytes"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of every delivery. SignatureHeader holds "sha256=" followed by
+// the hex HMAC-SHA256, keyed with the subscription secret, of the timestamp,
+// a dot and the body. Receivers should recompute it, and reject timestamps
+// too far off to keep old deliveries from being replayed.
+const (
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventHeader     = "X-Webhook-Event"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// maxDrainBytes bounds how much of a response is read so that the
+// connection can be reused.
+const maxDrainBytes = 64 << 10
+
+type HTTPSender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewHTTPSender posts with client. Attempts are bounded by the context they
+// are made with, so client needs no timeout of its own.
+func NewHTTPSender(client *http.Client) *HTTPSender {
+	return &HTTPSender{client: client, now: time.Now}
+}
+
+// Sign returns the signature of body sent at timestamp, as found in
+// SignatureHeader.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send reports any response outside 2xx as a failure.
+func (s *HTTPSender) Send(
+	ctx context.Context, subscription entity.WebhookSubscription, delivery entity.WebhookDelivery,
+) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, timestamp, delivery.Body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
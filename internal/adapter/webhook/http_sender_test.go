@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/domain/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type sendTestCase struct {
+	testName       string
+	receiverStatus int
+	expectError    bool
+}
+
+func TestHTTPSender_Send(t *testing.T) {
+	const secret = "0123456789abcdef"
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	delivery := entity.WebhookDelivery{
+		ID:        uuid.New(),
+		EventType: entity.UserCreated,
+		Body:      []byte(`{"type":"user.created"}`),
+	}
+
+	tests_scenarios := []sendTestCase{
+		{testName: "Delivered", receiverStatus: http.StatusNoContent},
+		{testName: "Receiver Failure", receiverStatus: http.StatusServiceUnavailable, expectError: true},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			var received *http.Request
+			var body []byte
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.receiverStatus)
+			}))
+			defer receiver.Close()
+
+			sender := NewHTTPSender(receiver.Client())
+			sender.now = func() time.Time { return now }
+			subscription := entity.WebhookSubscription{ID: uuid.New(), URL: receiver.URL, Secret: secret}
+			statusCode, err := sender.Send(context.Background(), subscription, delivery)
+
+			assert.Equal(t, tt.receiverStatus, statusCode, "should report the status code")
+			if tt.expectError {
+				assert.Error(t, err, "should report responses outside 2xx")
+			} else {
+				assert.NoError(t, err, "should not return an error")
+			}
+
+			assert.Equal(t, http.MethodPost, received.Method)
+			assert.Equal(t, delivery.Body, body, "should post the delivery body")
+			assert.Equal(t, "1717200000", received.Header.Get(TimestampHeader))
+			assert.Equal(t, delivery.ID.String(), received.Header.Get(DeliveryHeader))
+			assert.Equal(t, entity.UserCreated, received.Header.Get(EventHeader))
+			expected := Sign(secret, received.Header.Get(TimestampHeader), body)
+			assert.True(t, hmac.Equal([]byte(expected), []byte(received.Header.Get(SignatureHeader))),
+				"should sign the timestamp and body with the secret",
+			)
+			assert.NotEqual(t, Sign("another secret!!", "1717200000", body), received.Header.Get(SignatureHeader))
+		})
+	}
+}
+
+func TestHTTPSender_SendUnreachable(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	receiver.Close()
+
+	sender := NewHTTPSender(http.DefaultClient)
+	subscription := entity.WebhookSubscription{URL: receiver.URL, Secret: "0123456789abcdef"}
+	statusCode, err := sender.Send(context.Background(), subscription, entity.WebhookDelivery{Body: []byte("{}")})
+
+	assert.Error(t, err, "should report unreachable receivers")
+	assert.Zero(t, statusCode, "should not report a status code without a response")
+}
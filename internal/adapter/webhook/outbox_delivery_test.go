@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/adapter/publisher"
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/domain/entity"
+	"clean-go-rest-api/internal/usecase"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOutboxEventDelivery follows an event from the outbox, through the
+// dispatcher wired as in main, to a signed request to the subscriber.
+func TestOutboxEventDelivery(t *testing.T) {
+	const secret = "0123456789abcdef"
+	type receivedRequest struct {
+		header http.Header
+		body   []byte
+	}
+	received := make(chan receivedRequest, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedRequest{header: r.Header, body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	log := logger.NewLogger()
+	webhooks := usecase.SetupMockWebhookRepo()
+	_, err := usecase.NewWebhookUseCase(webhooks).Subscribe(ctx, dto.CreateWebhookRequest{
+		URL: receiver.URL, EventTypes: []string{entity.UserCreated}, Secret: secret,
+	})
+	assert.NoError(t, err, "should subscribe the receiver")
+
+	outbox := usecase.SetupMockOutboxRepo()
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", Version: 1}
+	event := entity.NewUserEvent(entity.UserCreated, nil, &user)
+	assert.NoError(t, outbox.Add(ctx, event))
+
+	dispatcher := usecase.NewOutboxDispatcher(
+		usecase.SetupMockUnitOfWork(),
+		outbox,
+		publisher.NewMultiPublisher(publisher.NewLogPublisher(log), usecase.NewWebhookPublisher(webhooks)),
+		log,
+		time.Hour,
+		3,
+	)
+	dispatched, err := dispatcher.DispatchOnce(ctx)
+	assert.NoError(t, err, "should dispatch the event")
+	assert.Equal(t, 1, dispatched)
+
+	deliverer := usecase.NewWebhookDeliverer(webhooks, NewHTTPSender(receiver.Client()), log, time.Hour, time.Second, 3)
+	delivered, err := deliverer.DeliverOnce(ctx)
+	assert.NoError(t, err, "should deliver the webhook")
+	assert.Equal(t, 1, delivered)
+
+	select {
+	case request := <-received:
+		assert.Equal(t, entity.UserCreated, request.header.Get(EventHeader))
+		assert.Equal(t,
+			Sign(secret, request.header.Get(TimestampHeader), request.body), request.header.Get(SignatureHeader),
+			"should sign the body with the subscription secret",
+		)
+		var envelope struct {
+			ID   uuid.UUID       `json:"id"`
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(request.body, &envelope))
+		assert.Equal(t, event.ID, envelope.ID, "should carry the ID of the event")
+		assert.JSONEq(t, string(event.Payload), string(envelope.Data), "should carry the payload of the event")
+	default:
+		t.Fatal("the receiver got no delivery")
+	}
+}
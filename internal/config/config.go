@@ -3,12 +3,48 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
-	ServerPort int
-	TimeZone   string
-	DB         DatabaseConfig
+	ServerPort  int
+	TimeZone    string
+	DB          DatabaseConfig
+	Purge       PurgeConfig
+	Idempotency IdempotencyConfig
+	Outbox      OutboxConfig
+	Webhook     WebhookConfig
+}
+
+// PurgeConfig controls how long deleted users are kept before being
+// permanently removed, and how often that is checked.
+type PurgeConfig struct {
+	UserRetention time.Duration
+	Interval      time.Duration
+}
+
+// IdempotencyConfig controls how long idempotency keys, and the responses
+// they replay, are kept, how long a key is held for a request in progress
+// and how often expired keys are purged.
+type IdempotencyConfig struct {
+	KeyTTL        time.Duration
+	KeyLease      time.Duration
+	PurgeInterval time.Duration
+}
+
+// OutboxConfig controls how often pending domain events are published and
+// how many attempts are made before giving up on one.
+type OutboxConfig struct {
+	DispatchInterval time.Duration
+	MaxAttempts      int
+}
+
+// WebhookConfig controls how often due webhooks are sent, how long an
+// attempt may take and how many attempts are made before giving up.
+type WebhookConfig struct {
+	DeliveryInterval time.Duration
+	Timeout          time.Duration
+	MaxAttempts      int
 }
 
 type DatabaseConfig struct {
@@ -43,7 +79,34 @@ func LoadConfig() *Config {
 			),
 			Parameters: getEnv("DB_PARAMETERS", ""),
 		},
+		Purge: PurgeConfig{
+			UserRetention: getDuration("USER_RETENTION_PERIOD", 30*24*time.Hour),
+			Interval:      getDuration("USER_PURGE_INTERVAL", time.Hour),
+		},
+		Idempotency: IdempotencyConfig{
+			KeyTTL:        getDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+			KeyLease:      getDuration("IDEMPOTENCY_KEY_LEASE", time.Minute),
+			PurgeInterval: getDuration("IDEMPOTENCY_KEY_PURGE_INTERVAL", time.Hour),
+		},
+		Outbox: OutboxConfig{
+			DispatchInterval: getDuration("OUTBOX_DISPATCH_INTERVAL", time.Second),
+			MaxAttempts:      getPositiveInt("OUTBOX_MAX_ATTEMPTS", 10),
+		},
+		Webhook: WebhookConfig{
+			DeliveryInterval: getDuration("WEBHOOK_DELIVERY_INTERVAL", time.Second),
+			Timeout:          getDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:      getPositiveInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		},
+	}
+}
+
+// Location resolves TimeZone, falling back to UTC when it is unknown.
+func (c *Config) Location() (*time.Location, error) {
+	location, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return time.UTC, err
 	}
+	return location, nil
 }
 
 func (c *Config) DBConnectionString() string {
@@ -67,3 +130,19 @@ func atoiOrDefault(s string, def int) int {
 	}
 	return v
 }
+
+func getDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+func getPositiveInt(key string, def int) int {
+	v := atoiOrDefault(getEnv(key, ""), def)
+	if v <= 0 {
+		return def
+	}
+	return v
+}
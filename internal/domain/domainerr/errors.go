@@ -0,0 +1,59 @@
+// Clean Architecture - Domain Layer
+// Typed domain errors shared by use cases and adapters
+package domainerr
+
+import "errors"
+
+// Sentinel kinds. Use errors.Is against these to classify a domain error
+// without depending on its message.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrValidation         = errors.New("validation failed")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrUnavailable        = errors.New("service unavailable")
+)
+
+// FieldError points at a single offending input field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Error is a domain failure carrying one of the sentinel kinds above and a
+// human readable message. Validation errors also list the offending fields.
+type Error struct {
+	Kind    error
+	Message string
+	Fields  []FieldError
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func NotFound(message string) error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+func Conflict(message string) error {
+	return &Error{Kind: ErrConflict, Message: message}
+}
+
+func Validation(message string, fields ...FieldError) error {
+	return &Error{Kind: ErrValidation, Message: message, Fields: fields}
+}
+
+func PreconditionFailed(message string) error {
+	return &Error{Kind: ErrPreconditionFailed, Message: message}
+}
+
+// Unavailable reports that a dependency, such as the database, could not
+// be reached. Unlike other failures it is worth retrying later.
+func Unavailable(message string) error {
+	return &Error{Kind: ErrUnavailable, Message: message}
+}
@@ -0,0 +1,79 @@
+package domainerr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type domainErrorTestCase struct {
+	testName string
+	err      error
+	kind     error
+	message  string
+}
+
+func TestDomainErrors(t *testing.T) {
+	tests_scenarios := []domainErrorTestCase{
+		{
+			testName: "Not Found",
+			err:      NotFound("user not found"),
+			kind:     ErrNotFound,
+			message:  "user not found",
+		},
+		{
+			testName: "Conflict",
+			err:      Conflict("user already exists"),
+			kind:     ErrConflict,
+			message:  "user already exists",
+		},
+		{
+			testName: "Validation",
+			err:      Validation("invalid email"),
+			kind:     ErrValidation,
+			message:  "invalid email",
+		},
+		{
+			testName: "Precondition Failed",
+			err:      PreconditionFailed("version mismatch"),
+			kind:     ErrPreconditionFailed,
+			message:  "version mismatch",
+		},
+		{
+			testName: "Unavailable",
+			err:      Unavailable("database unavailable"),
+			kind:     ErrUnavailable,
+			message:  "database unavailable",
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			assert.ErrorIs(t, tt.err, tt.kind, "should match its sentinel kind")
+			assert.EqualError(t, tt.err, tt.message, "should keep the original message")
+
+			wrapped := fmt.Errorf("wrapped: %w", tt.err)
+			assert.ErrorIs(t, wrapped, tt.kind, "should match its kind when wrapped")
+
+			var domainErr *Error
+			assert.True(t, errors.As(wrapped, &domainErr), "should unwrap to *Error")
+		})
+	}
+}
+
+func TestDomainErrors_ValidationFields(t *testing.T) {
+	err := Validation(
+		"invalid user",
+		FieldError{Field: "name", Message: "must not be empty"},
+		FieldError{Field: "email", Message: "must be a valid email address"},
+	)
+
+	var domainErr *Error
+	assert.True(t, errors.As(err, &domainErr), "should unwrap to *Error")
+	assert.ErrorIs(t, err, ErrValidation, "should be a validation error")
+	assert.Len(t, domainErr.Fields, 2, "should keep every offending field")
+	assert.Equal(t, "name", domainErr.Fields[0].Field)
+	assert.Equal(t, "email", domainErr.Fields[1].Field)
+}
@@ -2,7 +2,14 @@
 // Use case input/output DTOs
 package dto
 
-import "github.com/google/uuid"
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type CreateUserRequest struct {
 	Name  string `json:"name"`
@@ -13,16 +20,198 @@ type CreateUserResponse struct {
 	ID uuid.UUID `json:"id"`
 }
 
+// CreateUsersRequest creates several users at once. Unless BestEffort is
+// set, either every user is created or none is.
+type CreateUsersRequest struct {
+	Users      []CreateUserRequest
+	BestEffort bool
+}
+
+// CreateUserResult is the outcome for one user of a batch: its ID when it
+// was created, or why it was not.
+type CreateUserResult struct {
+	ID  uuid.UUID
+	Err error
+}
+
+// BatchItemResponse reports one item of a batch, by its position in the
+// request, with the status it would have had as a request of its own.
+type BatchItemResponse struct {
+	Index  int             `json:"index"`
+	Status int             `json:"status"`
+	ID     *uuid.UUID      `json:"id,omitempty"`
+	Error  *ProblemDetails `json:"error,omitempty"`
+}
+
+type CreateUsersResponse struct {
+	Results []BatchItemResponse `json:"results"`
+}
+
+// Versions on the write requests are the versions the client based its
+// change on, taken from If-Match. None means any version.
+
 type UpdateUserRequest struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Versions []int64   `json:"-"`
+}
+
+// PatchUserRequest carries the members of an RFC 7396 merge patch. A nil
+// field was absent from the patch and is left untouched.
+type PatchUserRequest struct {
+	ID       uuid.UUID
+	Name     *string
+	Email    *string
+	Versions []int64
+}
+
+type DeleteUserRequest struct {
+	ID       uuid.UUID `json:"id"`
+	Versions []int64   `json:"-"`
+}
+
+// SearchUsersRequest holds the raw search parameters, all optional.
+// Mode "fuzzy" ranks users by how similar their name or email is to Query.
+// EmailDomain is the part of the address after "@". Timestamps are
+// RFC 3339 and Sort is a field name, prefixed with "-" for descending order.
+// Cursor is the opaque token of the page to continue from.
+type SearchUsersRequest struct {
+	Mode           string
+	Query          string
+	Name           string
+	Email          string
+	EmailDomain    string
+	CreatedAfter   string
+	CreatedBefore  string
+	UpdatedAfter   string
+	UpdatedBefore  string
+	IncludeDeleted bool
+	Sort           string
+	Limit          string
+	Cursor         string
+}
+
+// SuggestUsersRequest holds the raw autocomplete parameters. Limit is
+// optional.
+type SuggestUsersRequest struct {
+	Prefix string
+	Limit  string
+}
+
+// ImportUsersRequest carries a CSV file whose header names a "name" and an
+// "email" column. With DryRun set, the report is produced without creating
+// anyone.
+type ImportUsersRequest struct {
+	CSV    io.Reader
+	DryRun bool
+}
+
+// ImportUsersReport sums up an import. Rejected lists, by line, the rows that
+// were not created and why.
+type ImportUsersReport struct {
+	DryRun   bool              `json:"dry_run"`
+	Created  int64             `json:"created"`
+	Rejected []ImportRejection `json:"rejected"`
+}
+
+type ImportRejection struct {
+	Line   int          `json:"line"`
+	Email  string       `json:"email,omitempty"`
+	Reason string       `json:"reason"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+type RestoreUserRequest struct {
+	ID       uuid.UUID `json:"id"`
+	Versions []int64   `json:"-"`
+}
+
+type UserResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Score     *float64   `json:"score,omitempty"`
+}
+
+// NewUserResponse renders user with its timestamps in location.
+func NewUserResponse(user entity.User, location *time.Location) UserResponse {
+	response := UserResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt.In(location),
+		UpdatedAt: user.UpdatedAt.In(location),
+	}
+	if user.IsDeleted() {
+		deletedAt := user.DeletedAt.In(location)
+		response.DeletedAt = &deletedAt
+	}
+	return response
+}
+
+func NewUserResponses(users []entity.User, location *time.Location) []UserResponse {
+	responses := make([]UserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, NewUserResponse(user, location))
+	}
+	return responses
+}
+
+// UserPageResponse is one page of users. Next links to the following page
+// and is left out on the last one.
+type UserPageResponse struct {
+	Data []UserResponse `json:"data"`
+	Next string         `json:"next,omitempty"`
+}
+
+// NewUserPageResponse renders page, scoring the users of a fuzzy search. The
+// link to the next page is left to the caller, who knows the request URL.
+func NewUserPageResponse(page entity.UserPage, location *time.Location) UserPageResponse {
+	response := UserPageResponse{Data: NewUserResponses(page.Users, location)}
+	for i, user := range page.Users {
+		if score, ok := page.Scores[user.ID]; ok {
+			response.Data[i].Score = &score
+		}
+	}
+	return response
+}
+
+type UserSuggestionResponse struct {
 	ID    uuid.UUID `json:"id"`
 	Name  string    `json:"name"`
 	Email string    `json:"email"`
 }
 
-type DeleteUserRequest struct {
-	ID uuid.UUID `json:"id"`
+func NewUserSuggestionResponses(suggestions []entity.UserSuggestion) []UserSuggestionResponse {
+	responses := make([]UserSuggestionResponse, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		responses = append(responses, UserSuggestionResponse(suggestion))
+	}
+	return responses
+}
+
+// UserEventResponse is a change to a user, as streamed to live clients.
+// Data is the event payload: the user before and after the change.
+type UserEventResponse struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	UserID     uuid.UUID       `json:"user_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
 }
 
-type ErrorResponse struct {
-	Reason string `json:"reason"`
+func NewUserEventResponse(event entity.OutboxEvent, location *time.Location) UserEventResponse {
+	return UserEventResponse{
+		ID:         event.ID,
+		Type:       event.Type,
+		UserID:     event.AggregateID,
+		OccurredAt: event.OccurredAt.In(location),
+		Data:       json.RawMessage(event.Payload),
+	}
 }
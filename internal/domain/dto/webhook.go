@@ -0,0 +1,88 @@
+// Clean Architecture - Domain Layer
+// Webhook input/output DTOs
+package dto
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+}
+
+// WebhookSubscriptionResponse leaves the secret out: it is only ever known
+// to whoever registered the subscription.
+type WebhookSubscriptionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func NewWebhookSubscriptionResponse(
+	subscription entity.WebhookSubscription, location *time.Location,
+) WebhookSubscriptionResponse {
+	return WebhookSubscriptionResponse{
+		ID:         subscription.ID,
+		URL:        subscription.URL,
+		EventTypes: subscription.EventTypes,
+		CreatedAt:  subscription.CreatedAt.In(location),
+	}
+}
+
+// WebhookDeliveryResponse reports a delivery and its latest attempt.
+// NextAttemptAt is only set while the delivery is pending.
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Data []WebhookDeliveryResponse `json:"data"`
+}
+
+func NewWebhookDeliveriesResponse(
+	deliveries []entity.WebhookDelivery, location *time.Location,
+) WebhookDeliveriesResponse {
+	response := WebhookDeliveriesResponse{Data: make([]WebhookDeliveryResponse, 0, len(deliveries))}
+	for _, delivery := range deliveries {
+		item := WebhookDeliveryResponse{
+			ID:             delivery.ID,
+			EventID:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Status:         string(delivery.Status),
+			Attempts:       delivery.Attempts,
+			LastStatusCode: delivery.LastStatusCode,
+			LastError:      delivery.LastError,
+			Payload:        json.RawMessage(delivery.Body),
+			CreatedAt:      delivery.CreatedAt.In(location),
+			UpdatedAt:      delivery.UpdatedAt.In(location),
+		}
+		if delivery.Status == entity.WebhookDeliveryPending {
+			nextAttemptAt := delivery.NextAttemptAt.In(location)
+			item.NextAttemptAt = &nextAttemptAt
+		}
+		if !delivery.DeliveredAt.IsZero() {
+			deliveredAt := delivery.DeliveredAt.In(location)
+			item.DeliveredAt = &deliveredAt
+		}
+		response.Data = append(response.Data, item)
+	}
+	return response
+}
@@ -0,0 +1,46 @@
+// Clean Architecture - Domain Layer
+// Idempotency keys and repository interface
+package entity
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotentResponse is the response to the first request made with an
+// idempotency key, replayed verbatim to its retries.
+type IdempotentResponse struct {
+	StatusCode int
+	Header     map[string][]string
+	Body       []byte
+}
+
+// IdempotencyKey is a key sent by a client, claimed by the first request
+// using it within Scope. RequestHash fingerprints that request so the key
+// cannot be reused for another one. Response stays nil while the request is
+// in progress, and the key is only held for it until LockedUntil, so that a
+// retry can take over the key of a request that died without releasing it.
+type IdempotencyKey struct {
+	Scope       string
+	Key         string
+	RequestHash string
+	Response    *IdempotentResponse
+	LockedUntil time.Time
+	ExpiresAt   time.Time
+}
+
+type IIdempotencyRepository interface {
+	// Claim stores key, unless a live key with the same scope and key is
+	// already stored: that one is then returned and claimed is false.
+	// Expired keys are replaced as if they did not exist, and so are keys
+	// still in progress for the same request once LockedUntil has passed.
+	Claim(ctx context.Context, key IdempotencyKey) (existing IdempotencyKey, claimed bool, err error)
+	// Complete records the response of the request that claimed the key.
+	Complete(ctx context.Context, scope, key string, response IdempotentResponse) error
+	// Release gives up a key whose request did not complete, so that a
+	// retry can claim it again.
+	Release(ctx context.Context, scope, key string) error
+	// PurgeExpired removes up to limit keys that expired before the given
+	// time and reports how many were removed.
+	PurgeExpired(ctx context.Context, before time.Time, limit int) (int64, error)
+}
@@ -0,0 +1,109 @@
+// Clean Architecture - Domain Layer
+// Domain events relayed to other services through an outbox
+package entity
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of the events published about users.
+const (
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted"
+)
+
+// OutboxEvent is a domain event waiting in the outbox until it is
+// published. Payload is JSON and Attempts counts failed publications.
+type OutboxEvent struct {
+	ID          uuid.UUID
+	Type        string
+	AggregateID uuid.UUID
+	Payload     []byte
+	OccurredAt  time.Time
+	Attempts    int
+}
+
+// UserEventPayload is the payload of user events: the user before and
+// after the change. Before is nil for creations and After for deletions.
+type UserEventPayload struct {
+	Before *UserEventState `json:"before"`
+	After  *UserEventState `json:"after"`
+}
+
+type UserEventState struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+// NewUserEvent describes a change of a user from before to after, either of
+// which may be nil.
+func NewUserEvent(eventType string, before, after *User) OutboxEvent {
+	payload := UserEventPayload{Before: newUserEventState(before), After: newUserEventState(after)}
+	aggregate := payload.After
+	if aggregate == nil {
+		aggregate = payload.Before
+	}
+	// Marshalling plain fields cannot fail.
+	raw, _ := json.Marshal(payload)
+	return OutboxEvent{
+		ID:          uuid.New(),
+		Type:        eventType,
+		AggregateID: aggregate.ID,
+		Payload:     raw,
+		OccurredAt:  time.Now(),
+	}
+}
+
+func newUserEventState(user *User) *UserEventState {
+	if user == nil {
+		return nil
+	}
+	state := &UserEventState{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+	if user.IsDeleted() {
+		deletedAt := user.DeletedAt
+		state.DeletedAt = &deletedAt
+	}
+	return state
+}
+
+type IOutboxRepository interface {
+	// Add stores events, in the unit of work of ctx when there is one, so
+	// that they are only published if the changes they describe commit.
+	Add(ctx context.Context, events ...OutboxEvent) error
+	// ClaimPending returns up to limit unpublished events, oldest first,
+	// and locks them until the unit of work of ctx ends. Events claimed by
+	// another unit of work are skipped rather than waited for, and so are
+	// the later events of the same aggregate, which must not be published
+	// ahead of them. Events given up on are never returned.
+	ClaimPending(ctx context.Context, limit int) ([]OutboxEvent, error)
+	MarkDelivered(ctx context.Context, ids []uuid.UUID) error
+	// MarkFailed counts a failed publication of the event and keeps reason.
+	// When giveUp is set the event is set aside as failed for good.
+	MarkFailed(ctx context.Context, id uuid.UUID, reason string, giveUp bool) error
+	// LatestDelivered returns the last limit events delivered, in the order
+	// they were delivered.
+	LatestDelivered(ctx context.Context, limit int) ([]OutboxEvent, error)
+}
+
+// IEventPublisher delivers events outside of the service. Events may be
+// published more than once, so consumers must tolerate duplicates.
+type IEventPublisher interface {
+	Publish(ctx context.Context, event OutboxEvent) error
+}
@@ -0,0 +1,33 @@
+// Clean Architecture - Domain Layer
+// Unit of work spanning several repository calls
+package entity
+
+import "context"
+
+// TxIsolation is the isolation level of a unit of work.
+type TxIsolation int
+
+const (
+	// TxIsolationDefault leaves the choice to the database.
+	TxIsolationDefault TxIsolation = iota
+	TxIsolationReadCommitted
+	TxIsolationRepeatableRead
+	TxIsolationSerializable
+)
+
+type TxOptions struct {
+	Isolation TxIsolation
+	ReadOnly  bool
+}
+
+// IUnitOfWork runs several repository calls atomically. Repositories take
+// part in the unit of work through the context handed to fn, so fn must pass
+// that context, not its own, to every call.
+type IUnitOfWork interface {
+	// Do commits when fn succeeds and rolls back when it fails. A Do nested
+	// in another joins it through a savepoint: its failure only undoes its
+	// own work, and opts are ignored. The outermost Do retries fn when the
+	// database aborts it on a serialization failure or deadlock, so fn must
+	// be safe to run again.
+	Do(ctx context.Context, opts TxOptions, fn func(ctx context.Context) error) error
+}
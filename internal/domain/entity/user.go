@@ -2,19 +2,170 @@
 // Entities and repository interfaces for User
 package entity
 
-import "github.com/google/uuid"
+import (
+	"clean-go-rest-api/internal/domain/domainerr"
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/google/uuid"
+)
+
+const (
+	UserNameMaxLength  = 100
+	UserEmailMaxLength = 254
+)
+
+// User.Version is bumped on every change and backs optimistic concurrency
+// control: writes only succeed against the version they were based on.
+// Deleted users keep their row, with DeletedAt set, until they are purged.
 type User struct {
+	ID        uuid.UUID
+	Name      string
+	Email     string
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt time.Time
+}
+
+// UserSuggestion is the lightweight view of a user offered while typing.
+type UserSuggestion struct {
 	ID    uuid.UUID
 	Name  string
 	Email string
 }
 
+// IUserRepository.Update, Delete and Restore only apply when the stored
+// version still equals user.Version, failing with a precondition error
+// otherwise. Add, AddMany, Update and Restore return the users as stored.
+//
+// Deleted users are invisible to every method except GetByIdIncludingDeleted,
+// Restore, PurgeDeleted and searches with IncludeDeleted set.
 type IUserRepository interface {
-	Add(user User) error
-	Delete(user User) error
-	Update(user User) error
-	GetById(id uuid.UUID) (User, error)
-	Search(name string) ([]User, error)
-	EmailExists(email string) bool
+	Add(ctx context.Context, user User) (User, error)
+	// AddMany inserts users at once, returning those inserted and
+	// reporting those left out because their email is already in use.
+	// Unless partial is set, none of them are inserted when any is left out.
+	AddMany(ctx context.Context, users []User, partial bool) (inserted []User, conflicts map[uuid.UUID]bool, err error)
+	Delete(ctx context.Context, user User) error
+	Update(ctx context.Context, user User) (User, error)
+	Restore(ctx context.Context, user User) (User, error)
+	GetById(ctx context.Context, id uuid.UUID) (User, error)
+	GetByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (User, error)
+	Search(ctx context.Context, filter UserFilter) (UserPage, error)
+	// Stream calls fn with every user matching filter, in order, as they
+	// are read; Limit and After are ignored. It stops at the first error
+	// fn returns.
+	Stream(ctx context.Context, filter UserFilter, fn func(User) error) error
+	// Suggest lists up to limit users whose name or email starts with the
+	// lower case prefix, ignoring case. Users matched by name come first,
+	// ordered by name, then the others ordered by email.
+	Suggest(ctx context.Context, prefix string, limit int) ([]UserSuggestion, error)
+	// EmailExists reports whether a user that is not deleted has email.
+	EmailExists(ctx context.Context, email string) (bool, error)
+	// Import creates the users read from source whose email is free, and
+	// reports the others. With dryRun set nothing is written, but the
+	// result is the same as if it had been.
+	Import(ctx context.Context, source UserSource, dryRun bool) (UserImportResult, error)
+	// PurgeDeleted permanently removes up to limit users deleted before
+	// the given time and reports how many were removed.
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// NewUser builds a normalized and validated user at its first version.
+func NewUser(id uuid.UUID, name, email string) (User, error) {
+	user := User{ID: id, Version: 1}
+	if err := user.Change(name, email); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (u User) IsDeleted() bool {
+	return !u.DeletedAt.IsZero()
+}
+
+// Change replaces the user's name and email. The values are normalized
+// first and the user is left untouched when they are not valid.
+func (u *User) Change(name, email string) error {
+	candidate := *u
+	candidate.Name = NormalizeUserName(name)
+	candidate.Email = NormalizeUserEmail(email)
+	if err := candidate.Validate(); err != nil {
+		return err
+	}
+
+	u.Name = candidate.Name
+	u.Email = candidate.Email
+	return nil
+}
+
+// Validate reports every invalid field at once so clients can fix them in a
+// single round trip.
+func (u User) Validate() error {
+	var fields []domainerr.FieldError
+	if msg := validateUserName(u.Name); msg != "" {
+		fields = append(fields, domainerr.FieldError{Field: "name", Message: msg})
+	}
+	if msg := validateUserEmail(u.Email); msg != "" {
+		fields = append(fields, domainerr.FieldError{Field: "email", Message: msg})
+	}
+	if len(fields) > 0 {
+		return domainerr.Validation("invalid user", fields...)
+	}
+	return nil
+}
+
+func NormalizeUserName(name string) string {
+	return strings.TrimSpace(name)
+}
+
+// NormalizeUserEmail trims and lower-cases email so uniqueness checks are
+// not defeated by casing.
+func NormalizeUserEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func validateUserName(name string) string {
+	switch {
+	case name == "":
+		return "must not be empty"
+	case !utf8.ValidString(name):
+		return "must be valid UTF-8"
+	case utf8.RuneCountInString(name) > UserNameMaxLength:
+		return fmt.Sprintf("must be at most %d characters long", UserNameMaxLength)
+	case hasControlCharacters(name):
+		return "must not contain control characters"
+	}
+	return ""
+}
+
+func validateUserEmail(email string) string {
+	switch {
+	case email == "":
+		return "must not be empty"
+	case len(email) > UserEmailMaxLength:
+		return fmt.Sprintf("must be at most %d characters long", UserEmailMaxLength)
+	case hasControlCharacters(email):
+		return "must not contain control characters"
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "must be a valid email address"
+	}
+	at := strings.LastIndex(email, "@")
+	if domain := email[at+1:]; !strings.Contains(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+func hasControlCharacters(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
 }
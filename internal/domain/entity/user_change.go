@@ -0,0 +1,30 @@
+// Clean Architecture - Domain Layer
+// Notifications of user changes shared by every instance of the service
+package entity
+
+import "github.com/google/uuid"
+
+const (
+	// UserPurged is the type of the change of a user permanently removed.
+	UserPurged = "user.purged"
+
+	// UserChangesMissed is the type of the change sent in place of changes
+	// that may have been missed, as when the connection they are received
+	// on was lost. Anything derived from users should then be rebuilt.
+	UserChangesMissed = "user.changes_missed"
+
+	// UserEventPublished is the type of the change announcing that Event,
+	// recorded for a change of the user, was published by the outbox.
+	UserEventPublished = "user.event_published"
+)
+
+// UserChange announces that a user was changed, through any instance of the
+// service, with the type of the event recorded for it, or UserPurged.
+// Version is the version of the user after the change. Event is only set
+// for changes of type UserEventPublished.
+type UserChange struct {
+	Type    string
+	UserID  uuid.UUID
+	Version int64
+	Event   *OutboxEvent
+}
@@ -0,0 +1,102 @@
+// Clean Architecture - Domain Layer
+// Search criteria for User
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+type UserSortField string
+
+const (
+	UserSortByCreatedAt UserSortField = "created_at"
+	UserSortByUpdatedAt UserSortField = "updated_at"
+	UserSortByName      UserSortField = "name"
+	UserSortByEmail     UserSortField = "email"
+	// UserSortByRelevance orders fuzzy searches by how well users match.
+	UserSortByRelevance UserSortField = "relevance"
+)
+
+// userSortFields whitelists the fields users can be sorted by.
+var userSortFields = map[UserSortField]bool{
+	UserSortByCreatedAt: true,
+	UserSortByUpdatedAt: true,
+	UserSortByName:      true,
+	UserSortByEmail:     true,
+	UserSortByRelevance: true,
+}
+
+type UserSort struct {
+	Field      UserSortField
+	Descending bool
+}
+
+// DefaultUserSort lists the oldest users first.
+var DefaultUserSort = UserSort{Field: UserSortByCreatedAt}
+
+// RelevanceUserSort lists the best matches of a fuzzy search first.
+var RelevanceUserSort = UserSort{Field: UserSortByRelevance, Descending: true}
+
+// UserSearchMode selects how Query is matched.
+type UserSearchMode string
+
+const (
+	// UserSearchContains only applies the other criteria, Query is unused.
+	UserSearchContains UserSearchMode = "contains"
+	// UserSearchFuzzy keeps users whose name or email is similar to Query,
+	// tolerating typos, and scores each of them.
+	UserSearchFuzzy UserSearchMode = "fuzzy"
+)
+
+// ParseUserSearchMode reads a search mode, UserSearchContains by default.
+func ParseUserSearchMode(mode string) (UserSearchMode, bool) {
+	switch UserSearchMode(strings.ToLower(strings.TrimSpace(mode))) {
+	case "", UserSearchContains:
+		return UserSearchContains, true
+	case UserSearchFuzzy:
+		return UserSearchFuzzy, true
+	}
+	return "", false
+}
+
+// UserFilter narrows a user search. Zero values leave a criterion unset.
+// Name matches part of the name, Email the whole address and EmailDomain
+// what follows its "@". Deleted users are left out unless IncludeDeleted is
+// set. At most Limit users are returned, starting right after the After
+// cursor when it is set.
+type UserFilter struct {
+	Mode           UserSearchMode
+	Query          string
+	Name           string
+	Email          string
+	EmailDomain    string
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	UpdatedAfter   time.Time
+	UpdatedBefore  time.Time
+	IncludeDeleted bool
+	Sort           UserSort
+	Limit          int
+	After          *UserCursor
+}
+
+// ParseUserSort reads a sort expression such as "created_at" or
+// "-updated_at", where a leading minus sorts in descending order. An empty
+// expression yields DefaultUserSort; fields outside the whitelist are
+// rejected.
+func ParseUserSort(expr string) (UserSort, bool) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return DefaultUserSort, true
+	}
+
+	sort := UserSort{
+		Field:      UserSortField(strings.TrimPrefix(expr, "-")),
+		Descending: strings.HasPrefix(expr, "-"),
+	}
+	if !userSortFields[sort.Field] {
+		return UserSort{}, false
+	}
+	return sort, true
+}
@@ -0,0 +1,29 @@
+// Clean Architecture - Domain Layer
+// Bulk import of users
+package entity
+
+// UserImportRow is a user read from line Line of an import file.
+type UserImportRow struct {
+	Line int
+	User User
+}
+
+// UserSource yields the rows of an import one at a time and returns io.EOF
+// once exhausted. Any other error aborts the import.
+type UserSource func() (UserImportRow, error)
+
+// UserImportDuplicate is a row left out of an import because of its email.
+// Taken tells an email owned by an existing user apart from one repeating an
+// earlier row of the same import, of which only the first is created.
+type UserImportDuplicate struct {
+	Line  int
+	Email string
+	Taken bool
+}
+
+// UserImportResult holds the users an import created, as stored, and the
+// rows it left out.
+type UserImportResult struct {
+	Users      []User
+	Duplicates []UserImportDuplicate
+}
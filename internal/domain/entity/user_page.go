@@ -0,0 +1,120 @@
+// Clean Architecture - Domain Layer
+// Keyset pagination of user searches
+package entity
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidUserCursor is returned when a cursor was not issued by this API.
+var ErrInvalidUserCursor = errors.New("invalid user cursor")
+
+// UserPage is one page of a user search. Next is nil on the last page.
+// Scores holds the relevance of each user of a fuzzy search, from 0 to 1.
+type UserPage struct {
+	Users  []User
+	Next   *UserCursor
+	Scores map[uuid.UUID]float64
+}
+
+// UserCursor points right after the last user of a page in the order given
+// by Sort. Value is that user's sort key and ID breaks ties, so the next page
+// can be fetched with a keyset condition instead of an offset.
+type UserCursor struct {
+	Sort  UserSort
+	Value interface{}
+	ID    uuid.UUID
+}
+
+// NewUserCursor points right after last in the given order. score is the
+// relevance of last, only used when sorting by relevance.
+func NewUserCursor(sort UserSort, last User, score float64) UserCursor {
+	return UserCursor{Sort: sort, Value: sort.Value(last, score), ID: last.ID}
+}
+
+// Value is the key user is sorted by. Relevance is not a property of the
+// user, so its score is passed along.
+func (s UserSort) Value(user User, score float64) interface{} {
+	switch s.Field {
+	case UserSortByRelevance:
+		return score
+	case UserSortByUpdatedAt:
+		return user.UpdatedAt
+	case UserSortByName:
+		return user.Name
+	case UserSortByEmail:
+		return user.Email
+	}
+	return user.CreatedAt
+}
+
+// sortsByTime reports whether the sort key is a timestamp.
+func (s UserSort) sortsByTime() bool {
+	return s.Field == UserSortByCreatedAt || s.Field == UserSortByUpdatedAt
+}
+
+// userCursorToken is the wire format of a cursor. Clients only ever see it
+// base64 encoded and must treat it as opaque.
+type userCursorToken struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// Encode renders the cursor as an opaque, URL safe token.
+func (c UserCursor) Encode() string {
+	token := userCursorToken{Sort: string(c.Sort.Field), ID: c.ID}
+	if c.Sort.Descending {
+		token.Sort = "-" + token.Sort
+	}
+	switch value := c.Value.(type) {
+	case time.Time:
+		token.Value = value.UTC().Format(time.RFC3339Nano)
+	case string:
+		token.Value = value
+	case float64:
+		token.Value = strconv.FormatFloat(value, 'g', -1, 64)
+	}
+
+	raw, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeUserCursor reads a token produced by UserCursor.Encode.
+func DecodeUserCursor(encoded string) (UserCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return UserCursor{}, ErrInvalidUserCursor
+	}
+	var token userCursorToken
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return UserCursor{}, ErrInvalidUserCursor
+	}
+
+	sort, ok := ParseUserSort(token.Sort)
+	if !ok || token.Sort == "" {
+		return UserCursor{}, ErrInvalidUserCursor
+	}
+	cursor := UserCursor{Sort: sort, Value: token.Value, ID: token.ID}
+	switch {
+	case sort.Field == UserSortByRelevance:
+		score, err := strconv.ParseFloat(token.Value, 64)
+		if err != nil {
+			return UserCursor{}, ErrInvalidUserCursor
+		}
+		cursor.Value = score
+	case sort.sortsByTime():
+		value, err := time.Parse(time.RFC3339Nano, token.Value)
+		if err != nil {
+			return UserCursor{}, ErrInvalidUserCursor
+		}
+		cursor.Value = value
+	}
+	return cursor, nil
+}
@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type userCursorTestCase struct {
+	testName string
+	encoded  string
+}
+
+func TestDecodeUserCursor(t *testing.T) {
+	last := User{
+		ID:        uuid.New(),
+		UpdatedAt: time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC),
+	}
+	cursor := NewUserCursor(UserSort{Field: UserSortByUpdatedAt, Descending: true}, last, 0)
+
+	byName := NewUserCursor(UserSort{Field: UserSortByName}, User{ID: last.ID, Name: "John"}, 0)
+	byRelevance := NewUserCursor(RelevanceUserSort, last, 0.5714286)
+
+	tests_scenarios := []userCursorTestCase{
+		{testName: "Round Trip", encoded: cursor.Encode()},
+		{testName: "Round Trip By Name", encoded: byName.Encode()},
+		{testName: "Round Trip By Relevance", encoded: byRelevance.Encode()},
+		{testName: "Not Base64", encoded: "%%%"},
+		{testName: "Not JSON", encoded: "bm90IGpzb24"},
+		{testName: "Unknown Sort", encoded: "eyJzIjoicGFzc3dvcmQiLCJ2IjoiIn0"},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			decoded, err := DecodeUserCursor(tt.encoded)
+
+			switch tt.testName {
+			case tests_scenarios[0].testName:
+				assert.NoError(t, err)
+				assert.Equal(t, cursor, decoded)
+			case tests_scenarios[1].testName:
+				assert.NoError(t, err)
+				assert.Equal(t, byName, decoded)
+			case tests_scenarios[2].testName:
+				assert.NoError(t, err)
+				assert.Equal(t, byRelevance, decoded)
+			default:
+				assert.ErrorIs(t, err, ErrInvalidUserCursor)
+			}
+		})
+	}
+}
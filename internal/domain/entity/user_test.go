@@ -1,10 +1,14 @@
 package entity
 
 import (
+	"clean-go-rest-api/internal/domain/domainerr"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
 )
 
 type userEntityTestSuite struct {
@@ -51,3 +55,97 @@ func TestUserEntity(t *testing.T) {
 		})
 	}
 }
+
+type newUserTestCase struct {
+	testName      string
+	name          string
+	email         string
+	expectedName  string
+	expectedEmail string
+	invalidFields []string
+}
+
+func TestNewUser(t *testing.T) {
+	tests_scenarios := []newUserTestCase{
+		{
+			testName:      "Valid User Is Normalized",
+			name:          "  John Doe  ",
+			email:         " John.Doe@Example.com ",
+			expectedName:  "John Doe",
+			expectedEmail: "john.doe@example.com",
+		},
+		{
+			testName:      "Empty Fields",
+			name:          "   ",
+			email:         "",
+			invalidFields: []string{"name", "email"},
+		},
+		{
+			testName:      "Name Too Long",
+			name:          strings.Repeat("a", UserNameMaxLength+1),
+			email:         "john@example.com",
+			invalidFields: []string{"name"},
+		},
+		{
+			testName:      "Control Characters",
+			name:          "John\x00Doe",
+			email:         "john\n@example.com",
+			invalidFields: []string{"name", "email"},
+		},
+		{
+			testName:      "Invalid Email Syntax",
+			name:          "John Doe",
+			email:         "john.example.com",
+			invalidFields: []string{"email"},
+		},
+		{
+			testName:      "Email With Display Name",
+			name:          "John Doe",
+			email:         "John <john@example.com>",
+			invalidFields: []string{"email"},
+		},
+		{
+			testName:      "Email Without Domain Suffix",
+			name:          "John Doe",
+			email:         "john@localhost",
+			invalidFields: []string{"email"},
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			id := uuid.New()
+			user, err := NewUser(id, tt.name, tt.email)
+
+			if len(tt.invalidFields) == 0 {
+				assert.NoError(t, err, "should not return an error for a valid user")
+				assert.Equal(t, id, user.ID)
+				assert.Equal(t, int64(1), user.Version, "should start at the first version")
+				assert.Equal(t, tt.expectedName, user.Name)
+				assert.Equal(t, tt.expectedEmail, user.Email)
+				return
+			}
+
+			assert.ErrorIs(t, err, domainerr.ErrValidation, "should return a validation error")
+			assert.Equal(t, User{}, user, "should return an empty user")
+
+			var domainErr *domainerr.Error
+			assert.True(t, errors.As(err, &domainErr))
+			var fields []string
+			for _, field := range domainErr.Fields {
+				fields = append(fields, field.Field)
+			}
+			assert.Equal(t, tt.invalidFields, fields, "should report every offending field")
+		})
+	}
+}
+
+func TestUser_ChangeKeepsUserOnError(t *testing.T) {
+	user, err := NewUser(uuid.New(), "John Doe", "john@example.com")
+	assert.NoError(t, err)
+
+	err = user.Change("", "john@example.com")
+	assert.ErrorIs(t, err, domainerr.ErrValidation, "should return a validation error")
+	assert.Equal(t, "John Doe", user.Name, "should not change the name")
+	assert.Equal(t, "john@example.com", user.Email, "should not change the email")
+}
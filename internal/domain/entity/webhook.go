@@ -0,0 +1,129 @@
+// Clean Architecture - Domain Layer
+// Webhook subscriptions, their deliveries and repository interface
+package entity
+
+import (
+	"clean-go-rest-api/internal/domain/domainerr"
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSecretMinLength keeps secrets long enough for HMAC signatures to
+// be worth verifying.
+const WebhookSecretMinLength = 16
+
+// WebhookEventTypes lists the events subscriptions can ask for.
+var WebhookEventTypes = []string{UserCreated, UserUpdated, UserDeleted}
+
+// WebhookSubscription asks for the events of EventTypes to be posted to URL,
+// signed with Secret.
+type WebhookSubscription struct {
+	ID         uuid.UUID
+	URL        string
+	EventTypes []string
+	Secret     string
+	CreatedAt  time.Time
+}
+
+// NewWebhookSubscription builds a validated subscription. Event types are
+// deduplicated and sorted.
+func NewWebhookSubscription(
+	id uuid.UUID, rawURL string, eventTypes []string, secret string,
+) (WebhookSubscription, error) {
+	subscription := WebhookSubscription{ID: id, URL: rawURL, Secret: secret}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(subscription.EventTypes, eventType) {
+			subscription.EventTypes = append(subscription.EventTypes, eventType)
+		}
+	}
+	slices.Sort(subscription.EventTypes)
+
+	var fields []domainerr.FieldError
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fields = append(fields, domainerr.FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	}
+	if len(subscription.EventTypes) == 0 {
+		fields = append(fields, domainerr.FieldError{Field: "event_types", Message: "must not be empty"})
+	}
+	for _, eventType := range subscription.EventTypes {
+		if !slices.Contains(WebhookEventTypes, eventType) {
+			fields = append(fields, domainerr.FieldError{
+				Field:   "event_types",
+				Message: fmt.Sprintf("unknown event type %q", eventType),
+			})
+		}
+	}
+	if len(secret) < WebhookSecretMinLength {
+		fields = append(fields, domainerr.FieldError{
+			Field:   "secret",
+			Message: fmt.Sprintf("must be at least %d characters long", WebhookSecretMinLength),
+		})
+	}
+	if len(fields) > 0 {
+		return WebhookSubscription{}, domainerr.Validation("invalid webhook subscription", fields...)
+	}
+	return subscription, nil
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	// WebhookDeliveryDead is the dead-letter state of deliveries that kept
+	// failing. They are kept for inspection but no longer attempted.
+	WebhookDeliveryDead WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery is the delivery of one event to one subscription. Body is
+// the JSON posted to the subscriber. LastStatusCode and LastError describe
+// the latest attempt; the status code is zero when no response came back.
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Body           []byte
+	Status         WebhookDeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeliveredAt    time.Time
+}
+
+type IWebhookRepository interface {
+	AddSubscription(ctx context.Context, subscription WebhookSubscription) (WebhookSubscription, error)
+	// GetSubscription returns an empty subscription when there is none
+	// with id.
+	GetSubscription(ctx context.Context, id uuid.UUID) (WebhookSubscription, error)
+	// SubscriptionsFor lists the subscriptions to eventType.
+	SubscriptionsFor(ctx context.Context, eventType string) ([]WebhookSubscription, error)
+	// AddDeliveries stores pending deliveries, skipping those of an event
+	// to a subscription that already has one.
+	AddDeliveries(ctx context.Context, deliveries ...WebhookDelivery) error
+	// ClaimDue returns up to limit pending deliveries due by now and
+	// postpones them by lease, so that no other worker attempts them while
+	// they are in flight. Deliveries whose worker died become due again
+	// once the lease is over.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error)
+	// RecordAttempt stores the outcome of an attempt of delivery.
+	RecordAttempt(ctx context.Context, delivery WebhookDelivery) error
+	// Deliveries lists the latest deliveries to a subscription, newest
+	// first.
+	Deliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]WebhookDelivery, error)
+}
+
+// IWebhookSender posts a delivery to its subscriber. The status code is
+// returned whenever a response came back, along with an error unless it
+// reports success.
+type IWebhookSender interface {
+	Send(ctx context.Context, subscription WebhookSubscription, delivery WebhookDelivery) (statusCode int, err error)
+}
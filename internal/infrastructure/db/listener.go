@@ -0,0 +1,173 @@
+// Clean Architecture - Frameworks & Drivers Layer
+// Listener fanning out user change notifications to in-process subscribers
+package db
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Reconnections start after listenerMinReconnect and back off up to
+	// listenerMaxReconnect while the database stays unreachable.
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+
+	// listenerPingInterval is how long the listener may go without
+	// notifications before checking that its connection is still alive.
+	listenerPingInterval = 90 * time.Second
+
+	// listenerSubscriberBuffer is how many changes a subscriber may lag
+	// behind before missing some.
+	listenerSubscriberBuffer = 256
+)
+
+// Listener receives the user changes announced by every instance, including
+// this one, and the events published for them, as changes of type
+// entity.UserEventPublished, and hands them to its subscribers. Its
+// dedicated connection is re-established whenever it is lost. As changes
+// announced meanwhile are lost, subscribers are then sent a change of type
+// entity.UserChangesMissed, as are subscribers that fell behind.
+type Listener struct {
+	connectionString string
+	logger           logger.ILogger
+
+	mu          sync.Mutex
+	subscribers map[chan entity.UserChange]bool
+}
+
+func NewListener(connectionString string, logger logger.ILogger) *Listener {
+	return &Listener{
+		connectionString: connectionString,
+		logger:           logger,
+		subscribers:      make(map[chan entity.UserChange]bool),
+	}
+}
+
+// Subscribe returns the channel changes are sent on until cancel is called.
+func (l *Listener) Subscribe() (<-chan entity.UserChange, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	subscriber := make(chan entity.UserChange, listenerSubscriberBuffer)
+	l.subscribers[subscriber] = false
+	cancel := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subscribers[subscriber]; ok {
+			delete(l.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+	return subscriber, cancel
+}
+
+// Run listens until ctx is done.
+func (l *Listener) Run(ctx context.Context) {
+	listener := pq.NewListener(
+		l.connectionString, listenerMinReconnect, listenerMaxReconnect,
+		func(event pq.ListenerEventType, err error) {
+			switch event {
+			case pq.ListenerEventDisconnected:
+				l.logger.Error(fmt.Sprintf("Lost the connection listening to user changes: %v", err))
+			case pq.ListenerEventReconnected:
+				l.logger.Info("Listening to user changes again")
+			case pq.ListenerEventConnectionAttemptFailed:
+				l.logger.Error(fmt.Sprintf("Unable to connect to listen to user changes: %v", err))
+			}
+		},
+	)
+	// Closing the listener also ends a Listen still waiting for the
+	// database, and closes the Notify channel.
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for _, channel := range []string{UserChangesChannel, UserEventsChannel} {
+		if err := listener.Listen(channel); err != nil {
+			if ctx.Err() == nil {
+				l.logger.Error(fmt.Sprintf("Unable to listen to %s: %s", channel, err.Error()))
+			}
+			return
+		}
+	}
+
+	ping := time.NewTicker(listenerPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case notification, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+			l.receive(notification)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+// receive fans notification out. A nil notification is what pq sends once
+// the connection is re-established.
+func (l *Listener) receive(notification *pq.Notification) {
+	if notification == nil {
+		l.publish(entity.UserChange{Type: entity.UserChangesMissed})
+		return
+	}
+
+	if notification.Channel == UserEventsChannel {
+		var payload userEventPayload
+		if err := json.Unmarshal([]byte(notification.Extra), &payload); err != nil {
+			l.logger.Error(fmt.Sprintf("Ignoring malformed user event %q: %s", notification.Extra, err.Error()))
+			return
+		}
+		l.publish(entity.UserChange{
+			Type:   entity.UserEventPublished,
+			UserID: payload.AggregateID,
+			Event: &entity.OutboxEvent{
+				ID:          payload.ID,
+				Type:        payload.Type,
+				AggregateID: payload.AggregateID,
+				Payload:     payload.Payload,
+				OccurredAt:  payload.OccurredAt,
+			},
+		})
+		return
+	}
+
+	var payload userChangePayload
+	if err := json.Unmarshal([]byte(notification.Extra), &payload); err != nil {
+		l.logger.Error(fmt.Sprintf("Ignoring malformed user change %q: %s", notification.Extra, err.Error()))
+		return
+	}
+	l.publish(entity.UserChange{Type: payload.Type, UserID: payload.ID, Version: payload.Version})
+}
+
+// publish never blocks on subscribers. Those that cannot take change right
+// away are flagged, and sent entity.UserChangesMissed instead of the next
+// change that fits.
+func (l *Listener) publish(change entity.UserChange) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for subscriber, missed := range l.subscribers {
+		next := change
+		if missed {
+			next = entity.UserChange{Type: entity.UserChangesMissed}
+		}
+		select {
+		case subscriber <- next:
+			l.subscribers[subscriber] = false
+		default:
+			l.subscribers[subscriber] = true
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+type receiveTestCase struct {
+	testName     string
+	notification *pq.Notification
+	expected     []entity.UserChange
+}
+
+func TestListener_Receive(t *testing.T) {
+	userID := uuid.New()
+	eventID := uuid.New()
+	occurredAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests_scenarios := []receiveTestCase{
+		{
+			testName: "User Change",
+			notification: &pq.Notification{
+				Channel: UserChangesChannel,
+				Extra:   `{"type":"user.updated","id":"` + userID.String() + `","version":2}`,
+			},
+			expected: []entity.UserChange{{Type: entity.UserUpdated, UserID: userID, Version: 2}},
+		},
+		{
+			testName: "Published Event",
+			notification: &pq.Notification{
+				Channel: UserEventsChannel,
+				Extra: `{"id":"` + eventID.String() + `","type":"user.deleted","aggregate_id":"` + userID.String() +
+					`","payload":{"before":null,"after":null},"occurred_at":"2024-01-02T03:04:05Z"}`,
+			},
+			expected: []entity.UserChange{{
+				Type:   entity.UserEventPublished,
+				UserID: userID,
+				Event: &entity.OutboxEvent{
+					ID:          eventID,
+					Type:        entity.UserDeleted,
+					AggregateID: userID,
+					Payload:     []byte(`{"before":null,"after":null}`),
+					OccurredAt:  occurredAt,
+				},
+			}},
+		},
+		{
+			testName:     "Malformed Event",
+			notification: &pq.Notification{Channel: UserEventsChannel, Extra: "not json"},
+		},
+		{
+			testName:     "Reconnected",
+			notification: nil,
+			expected:     []entity.UserChange{{Type: entity.UserChangesMissed}},
+		},
+		{
+			testName:     "Malformed Payload",
+			notification: &pq.Notification{Channel: UserChangesChannel, Extra: "not json"},
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			listener := NewListener("", logger.NewLogger())
+			changes, cancel := listener.Subscribe()
+			other, cancelOther := listener.Subscribe()
+			defer cancelOther()
+
+			listener.receive(tt.notification)
+			cancel()
+
+			var received []entity.UserChange
+			for change := range changes {
+				received = append(received, change)
+			}
+			assert.Equal(t, tt.expected, received)
+			assert.Len(t, other, len(tt.expected), "should hand changes to every subscriber")
+		})
+	}
+}
+
+func TestListener_SubscriberFallingBehind(t *testing.T) {
+	listener := NewListener("", logger.NewLogger())
+	changes, cancel := listener.Subscribe()
+	defer cancel()
+
+	for i := 0; i < listenerSubscriberBuffer+2; i++ {
+		listener.publish(entity.UserChange{Type: entity.UserUpdated, UserID: uuid.New(), Version: int64(i)})
+	}
+	for i := 0; i < listenerSubscriberBuffer; i++ {
+		assert.Equal(t, int64(i), (<-changes).Version, "should keep the changes that fit")
+	}
+
+	listener.publish(entity.UserChange{Type: entity.UserDeleted, UserID: uuid.New()})
+	assert.Equal(t, entity.UserChangesMissed, (<-changes).Type, "should tell subscribers they missed changes")
+	listener.publish(entity.UserChange{Type: entity.UserDeleted, UserID: uuid.New()})
+	assert.Equal(t, entity.UserDeleted, (<-changes).Type, "should resume once caught up")
+}
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_updated_at_idx;
+DROP INDEX IF EXISTS users_created_at_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at;
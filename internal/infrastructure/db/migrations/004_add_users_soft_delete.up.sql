@@ -0,0 +1,8 @@
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- Emails only have to be unique among users that are not deleted, so a
+-- deleted account does not block signing up again with the same address.
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX users_email_active_key ON users (email) WHERE deleted_at IS NULL;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,3 @@
+CREATE INDEX users_name_idx ON users (name, id);
+CREATE INDEX users_email_idx ON users (email, id);
+CREATE INDEX users_email_domain_idx ON users (split_part(email, '@', 2));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_email_trgm_idx;
+DROP INDEX IF EXISTS users_name_trgm_idx;
+
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,5 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Serve both fuzzy matching (%, similarity) and substring ILIKE searches.
+CREATE INDEX users_name_trgm_idx ON users USING gin (name gin_trgm_ops);
+CREATE INDEX users_email_trgm_idx ON users USING gin (email gin_trgm_ops);
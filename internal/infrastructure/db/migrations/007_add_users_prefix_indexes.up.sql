@@ -0,0 +1,3 @@
+-- text_pattern_ops lets LIKE 'prefix%' use the index whatever the collation.
+CREATE INDEX users_name_prefix_idx ON users (lower(name) text_pattern_ops) WHERE deleted_at IS NULL;
+CREATE INDEX users_email_prefix_idx ON users (email text_pattern_ops) WHERE deleted_at IS NULL;
@@ -0,0 +1,5 @@
+-- Events that kept failing are set aside rather than retried forever.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX outbox_pending_idx ON outbox (occurred_at, id) WHERE delivered_at IS NULL AND failed_at IS NULL;
@@ -0,0 +1,2 @@
+-- The latest delivered events are read back to resync the user event feeds.
+CREATE INDEX IF NOT EXISTS outbox_delivered_idx ON outbox (delivered_at, occurred_at, id) WHERE delivered_at IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_email_prefix_idx;
+DROP INDEX IF EXISTS users_name_prefix_idx;
+CREATE INDEX users_name_prefix_idx ON users (lower(name) text_pattern_ops) WHERE deleted_at IS NULL;
+CREATE INDEX users_email_prefix_idx ON users (email text_pattern_ops) WHERE deleted_at IS NULL;
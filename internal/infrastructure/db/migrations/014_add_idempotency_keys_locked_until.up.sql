@@ -0,0 +1,5 @@
+-- A key in progress is only held until locked_until, after which a retry of
+-- the same request may take it over. Keys in progress when this runs belong
+-- to requests that did not survive the deploy, so their lock has lapsed.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE idempotency_keys ALTER COLUMN locked_until DROP DEFAULT;
@@ -0,0 +1,3 @@
+-- Claimed events are checked for older pending events of the same aggregate.
+CREATE INDEX IF NOT EXISTS outbox_pending_aggregate_idx ON outbox (aggregate_id, occurred_at, id)
+    WHERE delivered_at IS NULL AND failed_at IS NULL;
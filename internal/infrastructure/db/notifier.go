@@ -0,0 +1,81 @@
+// Clean Architecture - Frameworks & Drivers Layer
+// User change notifications over Postgres NOTIFY
+package db
+
+import (
+	"clean-go-rest-api/internal/adapter/repository"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Channels user changes, and the events published for them, are announced
+// on.
+const (
+	UserChangesChannel = "user_changes"
+	UserEventsChannel  = "user_events"
+)
+
+// userChangePayload is a change as sent on UserChangesChannel, well within
+// the 8000 bytes a notification may carry.
+type userChangePayload struct {
+	Type    string    `json:"type"`
+	ID      uuid.UUID `json:"id"`
+	Version int64     `json:"version"`
+}
+
+// userEventPayload is an event as sent on UserEventsChannel. With names and
+// emails bounded by validation it also stays within 8000 bytes.
+type userEventPayload struct {
+	ID          uuid.UUID       `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID uuid.UUID       `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+}
+
+// Notifier announces user changes with NOTIFY, which Postgres delivers to
+// every listening session once the transaction it was issued in commits.
+type Notifier struct{}
+
+func NewNotifier() *Notifier {
+	return &Notifier{}
+}
+
+// NotifyUserChanges sends every change with a single statement.
+func (n *Notifier) NotifyUserChanges(ctx context.Context, exec repository.Execer, changes ...entity.UserChange) error {
+	payloads := make([]string, 0, len(changes))
+	for _, change := range changes {
+		payload, err := json.Marshal(userChangePayload{Type: change.Type, ID: change.UserID, Version: change.Version})
+		if err != nil {
+			return err
+		}
+		payloads = append(payloads, string(payload))
+	}
+	_, err := exec.ExecContext(
+		ctx,
+		"SELECT pg_notify($1, payload) FROM unnest($2::text[]) AS payload",
+		UserChangesChannel, pq.Array(payloads),
+	)
+	return err
+}
+
+// NotifyUserEvent sends the whole event, for listeners not to read it back.
+func (n *Notifier) NotifyUserEvent(ctx context.Context, exec repository.Execer, event entity.OutboxEvent) error {
+	payload, err := json.Marshal(userEventPayload{
+		ID:          event.ID,
+		Type:        event.Type,
+		AggregateID: event.AggregateID,
+		Payload:     json.RawMessage(event.Payload),
+		OccurredAt:  event.OccurredAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = exec.ExecContext(ctx, "SELECT pg_notify($1, $2)", UserEventsChannel, string(payload))
+	return err
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/domain/entity"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// execerMock records the statement it is given.
+type execerMock struct {
+	query string
+	args  []interface{}
+}
+
+func (m *execerMock) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	m.query, m.args = query, args
+	return nil, nil
+}
+
+func TestNotifier_NotifyUserChanges(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+	exec := &execerMock{}
+
+	err := NewNotifier().NotifyUserChanges(context.Background(), exec,
+		entity.UserChange{Type: entity.UserCreated, UserID: first, Version: 1},
+		entity.UserChange{Type: entity.UserDeleted, UserID: second, Version: 3},
+	)
+
+	assert.NoError(t, err, "should not return an error")
+	assert.Contains(t, exec.query, "pg_notify", "should notify with a single statement")
+	assert.Equal(t, []interface{}{UserChangesChannel, pq.Array([]string{
+		`{"type":"user.created","id":"` + first.String() + `","version":1}`,
+		`{"type":"user.deleted","id":"` + second.String() + `","version":3}`,
+	})}, exec.args)
+}
+
+func TestNotifier_NotifyUserEvent(t *testing.T) {
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", Version: 1}
+	event := entity.NewUserEvent(entity.UserCreated, nil, &user)
+	event.OccurredAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	exec := &execerMock{}
+
+	err := NewNotifier().NotifyUserEvent(context.Background(), exec, event)
+
+	assert.NoError(t, err, "should not return an error")
+	assert.Contains(t, exec.query, "pg_notify")
+	assert.Equal(t, UserEventsChannel, exec.args[0])
+	assert.JSONEq(t, `{
+		"id":"`+event.ID.String()+`",
+		"type":"user.created",
+		"aggregate_id":"`+user.ID.String()+`",
+		"payload":`+string(event.Payload)+`,
+		"occurred_at":"2024-01-02T03:04:05Z"
+	}`, exec.args[1].(string), "should carry the whole event")
+}
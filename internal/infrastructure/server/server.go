@@ -4,17 +4,24 @@ package server
 
 import (
 	"clean-go-rest-api/internal/crosscutting/logger"
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func StartServer(router *mux.Router, port int, logger logger.ILogger) *http.Server {
+// StartServer serves router in the background. Every request context derives
+// from baseCtx, so canceling it aborts in-flight work such as database queries.
+func StartServer(baseCtx context.Context, router *mux.Router, port int, logger logger.ILogger) *http.Server {
 	addr := fmt.Sprintf(":%d", port)
 	httpServer := &http.Server{
 		Addr:    addr,
 		Handler: router,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
 	}
 	go func() {
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -0,0 +1,143 @@
+// Clean Architecture - Use Case Layer
+// Idempotency keys for safely retried requests
+package usecase
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/domainerr"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// MaxIdempotencyKeyLength caps the length of client supplied keys.
+const MaxIdempotencyKeyLength = 255
+
+// ErrIdempotencyKeyInProgress is the conflict returned while the first
+// request made with a key has not completed yet.
+var ErrIdempotencyKeyInProgress = domainerr.Conflict("a request with this idempotency key is in progress")
+
+// IIdempotencyUseCase lets a request run at most once per key within scope.
+// Begin either claims the key, returning a nil response, or returns the
+// response of the request that claimed it first. A claimed key is then
+// either completed with the response to replay, or released when the request
+// did not produce one worth replaying.
+type IIdempotencyUseCase interface {
+	Begin(ctx context.Context, scope, key string, request []byte) (*entity.IdempotentResponse, error)
+	Complete(ctx context.Context, scope, key string, response entity.IdempotentResponse) error
+	Release(ctx context.Context, scope, key string) error
+}
+
+type IdempotencyUseCase struct {
+	repo  entity.IIdempotencyRepository
+	ttl   time.Duration
+	lease time.Duration
+	now   func() time.Time
+}
+
+// NewIdempotencyUseCase keeps keys, and the responses they replay, for ttl.
+// A key in progress is held for lease, which must outlast the slowest
+// request: a retry made after that runs the request again.
+func NewIdempotencyUseCase(repo entity.IIdempotencyRepository, ttl, lease time.Duration) *IdempotencyUseCase {
+	return &IdempotencyUseCase{repo: repo, ttl: ttl, lease: lease, now: time.Now}
+}
+
+// Begin fingerprints request, which must hold everything that makes two
+// requests different, so that a key reused for another request is rejected
+// instead of replaying an unrelated response.
+func (u *IdempotencyUseCase) Begin(
+	ctx context.Context, scope, key string, request []byte,
+) (*entity.IdempotentResponse, error) {
+	if err := validateIdempotencyKey(key); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(request)
+	hash := hex.EncodeToString(sum[:])
+	now := u.now()
+	existing, claimed, err := u.repo.Claim(ctx, entity.IdempotencyKey{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: hash,
+		LockedUntil: now.Add(u.lease),
+		ExpiresAt:   now.Add(u.ttl),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	if existing.RequestHash != "" && existing.RequestHash != hash {
+		return nil, domainerr.Conflict("idempotency key was already used for a different request")
+	}
+	if existing.Response == nil {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	return existing.Response, nil
+}
+
+func (u *IdempotencyUseCase) Complete(
+	ctx context.Context, scope, key string, response entity.IdempotentResponse,
+) error {
+	return u.repo.Complete(ctx, scope, key, response)
+}
+
+func (u *IdempotencyUseCase) Release(ctx context.Context, scope, key string) error {
+	return u.repo.Release(ctx, scope, key)
+}
+
+func validateIdempotencyKey(key string) error {
+	var msg string
+	switch {
+	case key == "":
+		msg = "must not be empty"
+	case len(key) > MaxIdempotencyKeyLength:
+		msg = fmt.Sprintf("must be at most %d characters long", MaxIdempotencyKeyLength)
+	default:
+		for i := 0; i < len(key); i++ {
+			if key[i] < 0x20 || key[i] > 0x7e {
+				msg = "must only contain printable ASCII characters"
+				break
+			}
+		}
+	}
+	if msg != "" {
+		return domainerr.Validation("invalid idempotency key",
+			domainerr.FieldError{Field: "Idempotency-Key", Message: msg},
+		)
+	}
+	return nil
+}
+
+// IdempotencyKeyPurger removes keys once they have expired.
+type IdempotencyKeyPurger struct {
+	repo     entity.IIdempotencyRepository
+	logger   logger.ILogger
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewIdempotencyKeyPurger(
+	repo entity.IIdempotencyRepository, logger logger.ILogger, interval time.Duration,
+) *IdempotencyKeyPurger {
+	return &IdempotencyKeyPurger{repo: repo, logger: logger, interval: interval, now: time.Now}
+}
+
+// PurgeOnce removes every expired key, batch by batch, and reports how many
+// were removed.
+func (p *IdempotencyKeyPurger) PurgeOnce(ctx context.Context) (int64, error) {
+	cutoff := p.now()
+	return purgeInBatches(ctx, func(ctx context.Context, limit int) (int64, error) {
+		return p.repo.PurgeExpired(ctx, cutoff, limit)
+	})
+}
+
+// Run purges expired keys on every interval until ctx is done.
+func (p *IdempotencyKeyPurger) Run(ctx context.Context) {
+	runPurges(ctx, p.logger, p.interval, "expired idempotency keys", p.PurgeOnce)
+}
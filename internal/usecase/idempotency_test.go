@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/domainerr"
+	"clean-go-rest-api/internal/domain/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type idempotencyTestCase struct {
+	testName  string
+	repoSetup func(*IdempotencyRepositoryMock, *IdempotencyUseCase)
+	key       string
+	request   string
+	expected  error
+}
+
+func TestIdempotencyUseCase_Begin(t *testing.T) {
+	const scope = "POST /users"
+	stored := entity.IdempotentResponse{StatusCode: 201, Body: []byte(`{"id":"1"}`)}
+
+	// begin runs a first request with key, completing it unless response is nil.
+	begin := func(
+		key, request string, response *entity.IdempotentResponse,
+	) func(*IdempotencyRepositoryMock, *IdempotencyUseCase) {
+		return func(repo *IdempotencyRepositoryMock, useCase *IdempotencyUseCase) {
+			_, err := useCase.Begin(context.Background(), scope, key, []byte(request))
+			assert.NoError(t, err, "the first request should claim the key")
+			if response != nil {
+				assert.NoError(t, useCase.Complete(context.Background(), scope, key, *response))
+			}
+		}
+	}
+
+	tests_scenarios := []idempotencyTestCase{
+		{
+			testName:  "First Request",
+			repoSetup: func(*IdempotencyRepositoryMock, *IdempotencyUseCase) {},
+			key:       "abc",
+			request:   `{"name":"John"}`,
+		},
+		{
+			testName:  "Retry Replays Response",
+			repoSetup: begin("abc", `{"name":"John"}`, &stored),
+			key:       "abc",
+			request:   `{"name":"John"}`,
+		},
+		{
+			testName:  "Key Reused For Another Request",
+			repoSetup: begin("abc", `{"name":"John"}`, &stored),
+			key:       "abc",
+			request:   `{"name":"Jane"}`,
+			expected:  domainerr.ErrConflict,
+		},
+		{
+			testName:  "Retry While In Progress",
+			repoSetup: begin("abc", `{"name":"John"}`, nil),
+			key:       "abc",
+			request:   `{"name":"John"}`,
+			expected:  ErrIdempotencyKeyInProgress,
+		},
+		{
+			testName: "Released Key Runs Again",
+			repoSetup: func(repo *IdempotencyRepositoryMock, useCase *IdempotencyUseCase) {
+				begin("abc", `{"name":"John"}`, nil)(repo, useCase)
+				assert.NoError(t, useCase.Release(context.Background(), scope, "abc"))
+			},
+			key:     "abc",
+			request: `{"name":"John"}`,
+		},
+		{
+			testName: "Expired Key Runs Again",
+			repoSetup: func(repo *IdempotencyRepositoryMock, useCase *IdempotencyUseCase) {
+				begin("abc", `{"name":"John"}`, &stored)(repo, useCase)
+				repo.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+			},
+			key:     "abc",
+			request: `{"name":"Jane"}`,
+		},
+		{
+			testName: "Lapsed Lock Runs Again",
+			repoSetup: func(repo *IdempotencyRepositoryMock, useCase *IdempotencyUseCase) {
+				begin("abc", `{"name":"John"}`, nil)(repo, useCase)
+				repo.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+			},
+			key:     "abc",
+			request: `{"name":"John"}`,
+		},
+		{
+			testName: "Lapsed Lock Of Another Request",
+			repoSetup: func(repo *IdempotencyRepositoryMock, useCase *IdempotencyUseCase) {
+				begin("abc", `{"name":"John"}`, nil)(repo, useCase)
+				repo.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+			},
+			key:      "abc",
+			request:  `{"name":"Jane"}`,
+			expected: domainerr.ErrConflict,
+		},
+		{
+			testName:  "Invalid Key",
+			repoSetup: func(*IdempotencyRepositoryMock, *IdempotencyUseCase) {},
+			key:       strings.Repeat("k", MaxIdempotencyKeyLength+1),
+			expected:  domainerr.ErrValidation,
+		},
+		{
+			testName: "Error on Claim",
+			repoSetup: func(repo *IdempotencyRepositoryMock, _ *IdempotencyUseCase) {
+				repo.claimErr = errors.New("database error")
+			},
+			key:      "abc",
+			expected: errors.New("database error"),
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			repo := SetupMockIdempotencyRepo()
+			useCase := NewIdempotencyUseCase(repo, 24*time.Hour, time.Minute)
+			tt.repoSetup(repo, useCase)
+
+			response, err := useCase.Begin(context.Background(), scope, tt.key, []byte(tt.request))
+
+			switch tt.testName {
+			case tests_scenarios[0].testName, tests_scenarios[4].testName, tests_scenarios[5].testName,
+				tests_scenarios[6].testName:
+				assert.NoError(t, err, "should claim the key")
+				assert.Nil(t, response, "should let the request run")
+			case tests_scenarios[1].testName:
+				assert.NoError(t, err, "should not return an error")
+				assert.Equal(t, &stored, response, "should replay the first response")
+			case tests_scenarios[2].testName, tests_scenarios[3].testName, tests_scenarios[7].testName,
+				tests_scenarios[8].testName:
+				assert.ErrorIs(t, err, tt.expected, "should reject the request")
+				assert.Nil(t, response, "should not replay anything")
+			case tests_scenarios[9].testName:
+				assert.EqualError(t, err, tt.expected.Error(), "should return the repository error")
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyPurger_PurgeOnce(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	repo := SetupMockIdempotencyRepo()
+	for i := 0; i < purgeBatchSize+1; i++ {
+		repo.keys[fmt.Sprintf("expired %d", i)] = entity.IdempotencyKey{ExpiresAt: now.Add(-time.Minute)}
+	}
+	repo.keys["live"] = entity.IdempotencyKey{ExpiresAt: now.Add(time.Minute)}
+
+	purger := NewIdempotencyKeyPurger(repo, logger.NewLogger(), time.Hour)
+	purger.now = func() time.Time { return now }
+	purged, err := purger.PurgeOnce(context.Background())
+
+	assert.NoError(t, err, "should not return an error")
+	assert.Equal(t, int64(purgeBatchSize+1), purged, "should purge every expired key")
+	assert.Contains(t, repo.keys, "live", "should keep live keys")
+}
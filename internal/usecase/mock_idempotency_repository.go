@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"time"
+)
+
+// IdempotencyRepositoryMock keeps keys in memory, expiring them against now.
+type IdempotencyRepositoryMock struct {
+	keys     map[string]entity.IdempotencyKey
+	now      func() time.Time
+	claimErr error
+}
+
+func SetupMockIdempotencyRepo() *IdempotencyRepositoryMock {
+	return &IdempotencyRepositoryMock{keys: make(map[string]entity.IdempotencyKey), now: time.Now}
+}
+
+func (m *IdempotencyRepositoryMock) Claim(
+	ctx context.Context, key entity.IdempotencyKey,
+) (entity.IdempotencyKey, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.IdempotencyKey{}, false, err
+	}
+	if m.claimErr != nil {
+		return entity.IdempotencyKey{}, false, m.claimErr
+	}
+	id := key.Scope + " " + key.Key
+	if existing, ok := m.keys[id]; ok && existing.ExpiresAt.After(m.now()) {
+		lapsed := existing.Response == nil && existing.RequestHash == key.RequestHash &&
+			!existing.LockedUntil.After(m.now())
+		if !lapsed {
+			return existing, false, nil
+		}
+	}
+	m.keys[id] = key
+	return key, true, nil
+}
+
+func (m *IdempotencyRepositoryMock) Complete(
+	ctx context.Context, scope, key string, response entity.IdempotentResponse,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if existing, ok := m.keys[scope+" "+key]; ok {
+		existing.Response = &response
+		m.keys[scope+" "+key] = existing
+	}
+	return nil
+}
+
+func (m *IdempotencyRepositoryMock) Release(ctx context.Context, scope, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if existing, ok := m.keys[scope+" "+key]; ok && existing.Response == nil {
+		delete(m.keys, scope+" "+key)
+	}
+	return nil
+}
+
+func (m *IdempotencyRepositoryMock) PurgeExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var purged int64
+	for id, key := range m.keys {
+		if int(purged) == limit {
+			break
+		}
+		if key.ExpiresAt.Before(before) {
+			delete(m.keys, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// OutboxRepositoryMock keeps events in the order they were added.
+type OutboxRepositoryMock struct {
+	events    []entity.OutboxEvent
+	delivered map[uuid.UUID]bool
+	failures  map[uuid.UUID]string
+	dead      map[uuid.UUID]bool
+	addErr    error
+}
+
+func SetupMockOutboxRepo() *OutboxRepositoryMock {
+	return &OutboxRepositoryMock{
+		delivered: make(map[uuid.UUID]bool),
+		failures:  make(map[uuid.UUID]string),
+		dead:      make(map[uuid.UUID]bool),
+	}
+}
+
+func (m *OutboxRepositoryMock) Add(ctx context.Context, events ...entity.OutboxEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if m.addErr != nil {
+		return m.addErr
+	}
+	m.events = append(m.events, events...)
+	return nil
+}
+
+func (m *OutboxRepositoryMock) ClaimPending(ctx context.Context, limit int) ([]entity.OutboxEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var pending []entity.OutboxEvent
+	for _, event := range m.events {
+		if len(pending) == limit {
+			break
+		}
+		if !m.delivered[event.ID] && !m.dead[event.ID] {
+			pending = append(pending, event)
+		}
+	}
+	return pending, nil
+}
+
+func (m *OutboxRepositoryMock) MarkDelivered(ctx context.Context, ids []uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		m.delivered[id] = true
+	}
+	return nil
+}
+
+func (m *OutboxRepositoryMock) MarkFailed(ctx context.Context, id uuid.UUID, reason string, giveUp bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.failures[id] = reason
+	m.dead[id] = giveUp
+	for i := range m.events {
+		if m.events[i].ID == id {
+			m.events[i].Attempts++
+		}
+	}
+	return nil
+}
+
+// LatestDelivered takes the order events were added in as the order they
+// were delivered in.
+func (m *OutboxRepositoryMock) LatestDelivered(ctx context.Context, limit int) ([]entity.OutboxEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var delivered []entity.OutboxEvent
+	for _, event := range m.events {
+		if m.delivered[event.ID] {
+			delivered = append(delivered, event)
+		}
+	}
+	return delivered[max(0, len(delivered)-limit):], nil
+}
@@ -0,0 +1,24 @@
+package usecase
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+)
+
+// UnitOfWorkMock runs fn right away, counting the units of work it was
+// handed, as the in-memory repository mocks have no transactions.
+type UnitOfWorkMock struct {
+	units int
+}
+
+func SetupMockUnitOfWork() *UnitOfWorkMock {
+	return &UnitOfWorkMock{}
+}
+
+func (m *UnitOfWorkMock) Do(ctx context.Context, opts entity.TxOptions, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.units++
+	return fn(ctx)
+}
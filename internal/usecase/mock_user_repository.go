@@ -1,73 +1,446 @@
 package usecase
 
 import (
+	"clean-go-rest-api/internal/domain/domainerr"
 	"clean-go-rest-api/internal/domain/entity"
+	"cmp"
+	"context"
+	"io"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 )
 
+// UserRepositoryMock fails every call with ctx.Err() once the context is
+// done so use case cancellation can be asserted in tests.
 type UserRepositoryMock struct {
 	users      map[string]entity.User
 	emailExist bool
-	addErr     error
-	getByIdErr error
-	updateErr  error
-	deleteErr  error
+	// emailExistsErr fails EmailExists, as the database failing would.
+	emailExistsErr error
+	addErr         error
+	getByIdErr     error
+	updateErr      error
+	deleteErr      error
 }
 
 func SetupMockRepo() *UserRepositoryMock {
 	return &UserRepositoryMock{users: make(map[string]entity.User)}
 }
 
-func (m *UserRepositoryMock) Add(user entity.User) error {
+func (m *UserRepositoryMock) Add(ctx context.Context, user entity.User) (entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.User{}, err
+	}
 	if m.getByIdErr != nil {
-		return m.getByIdErr
+		return entity.User{}, m.getByIdErr
 	}
 	if m.addErr != nil {
-		return m.addErr
+		return entity.User{}, m.addErr
 	}
+	// Mirrors the unique index on the email of users that are not deleted.
+	if m.emailTaken(user.Email) {
+		return entity.User{}, domainerr.Conflict("user already exists")
+	}
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
 	m.users[user.ID.String()] = user
-	return nil
+	return user, nil
+}
+
+func (m *UserRepositoryMock) emailTaken(email string) bool {
+	if m.emailExist {
+		return true
+	}
+	for _, u := range m.users {
+		if u.Email == email && !u.IsDeleted() {
+			return true
+		}
+	}
+	return false
 }
 
-func (m *UserRepositoryMock) Delete(user entity.User) error {
+func (m *UserRepositoryMock) AddMany(
+	ctx context.Context, users []entity.User, partial bool,
+) ([]entity.User, map[uuid.UUID]bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if m.addErr != nil {
+		return nil, nil, m.addErr
+	}
+	taken := make(map[string]bool)
+	for _, u := range m.users {
+		if !u.IsDeleted() {
+			taken[u.Email] = true
+		}
+	}
+	conflicts := make(map[uuid.UUID]bool)
+	var inserted []entity.User
+	for _, user := range users {
+		if taken[user.Email] {
+			conflicts[user.ID] = true
+			continue
+		}
+		taken[user.Email] = true
+		user.CreatedAt = time.Now()
+		user.UpdatedAt = user.CreatedAt
+		inserted = append(inserted, user)
+	}
+	if len(conflicts) > 0 && !partial {
+		return nil, conflicts, nil
+	}
+	for _, user := range inserted {
+		m.users[user.ID.String()] = user
+	}
+	return inserted, conflicts, nil
+}
+
+func (m *UserRepositoryMock) Delete(ctx context.Context, user entity.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if m.deleteErr != nil {
 		return m.deleteErr
 	}
-	delete(m.users, user.ID.String())
+	if err := m.checkVersion(user, false); err != nil {
+		return err
+	}
+	user.Version++
+	user.DeletedAt = time.Now()
+	m.users[user.ID.String()] = user
 	return nil
 }
 
-func (m *UserRepositoryMock) Update(user entity.User) error {
+func (m *UserRepositoryMock) Update(ctx context.Context, user entity.User) (entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.User{}, err
+	}
 	if m.updateErr != nil {
-		return m.updateErr
+		return entity.User{}, m.updateErr
+	}
+	if err := m.checkVersion(user, false); err != nil {
+		return entity.User{}, err
+	}
+	user.Version++
+	user.UpdatedAt = time.Now()
+	m.users[user.ID.String()] = user
+	return user, nil
+}
+
+func (m *UserRepositoryMock) Restore(ctx context.Context, user entity.User) (entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.User{}, err
 	}
+	if err := m.checkVersion(user, true); err != nil {
+		return entity.User{}, err
+	}
+	user.Version++
+	user.UpdatedAt = time.Now()
+	user.DeletedAt = time.Time{}
 	m.users[user.ID.String()] = user
+	return user, nil
+}
+
+// checkVersion mirrors the conditional writes of the Postgres repository,
+// which only see deleted users when restoring.
+func (m *UserRepositoryMock) checkVersion(user entity.User, deleted bool) error {
+	stored, ok := m.users[user.ID.String()]
+	if !ok || stored.IsDeleted() != deleted {
+		return domainerr.NotFound("user not found")
+	}
+	if stored.Version != user.Version {
+		return domainerr.PreconditionFailed("user was modified by another request")
+	}
 	return nil
 }
 
-func (m *UserRepositoryMock) GetById(id uuid.UUID) (entity.User, error) {
+func (m *UserRepositoryMock) GetById(ctx context.Context, id uuid.UUID) (entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.User{}, err
+	}
 	if m.getByIdErr != nil {
 		return entity.User{}, m.getByIdErr
 	}
 	user, ok := m.users[id.String()]
-	if !ok {
+	if !ok || user.IsDeleted() {
 		return entity.User{ID: uuid.Nil}, nil
 	}
 	return user, nil
 }
 
-func (m *UserRepositoryMock) Search(name string) ([]entity.User, error) {
+func (m *UserRepositoryMock) GetByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.User{}, err
+	}
+	if m.getByIdErr != nil {
+		return entity.User{}, m.getByIdErr
+	}
+	return m.users[id.String()], nil
+}
+
+func (m *UserRepositoryMock) Search(ctx context.Context, filter entity.UserFilter) (entity.UserPage, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.UserPage{}, err
+	}
+	fuzzy := filter.Mode == entity.UserSearchFuzzy
+	scores := make(map[uuid.UUID]float64)
 	var result []entity.User
 	for _, u := range m.users {
-		if strings.Contains(strings.ToLower(u.Name), strings.ToLower(name)) {
-			result = append(result, u)
+		if u.IsDeleted() && !filter.IncludeDeleted {
+			continue
+		}
+		if fuzzy {
+			score := max(similarity(u.Name, filter.Query), similarity(u.Email, filter.Query))
+			if score < similarityThreshold {
+				continue
+			}
+			scores[u.ID] = score
+		}
+		if !strings.Contains(strings.ToLower(u.Name), strings.ToLower(filter.Name)) {
+			continue
+		}
+		if filter.Email != "" && u.Email != filter.Email {
+			continue
+		}
+		if filter.EmailDomain != "" && !strings.HasSuffix(u.Email, "@"+filter.EmailDomain) {
+			continue
+		}
+		if !inTimeRange(u.CreatedAt, filter.CreatedAfter, filter.CreatedBefore) ||
+			!inTimeRange(u.UpdatedAt, filter.UpdatedAfter, filter.UpdatedBefore) {
+			continue
+		}
+		if filter.After != nil && !followsCursor(*filter.After, u, scores[u.ID]) {
+			continue
+		}
+		result = append(result, u)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return compareUsers(filter.Sort, result[i], result[j], scores) < 0
+	})
+
+	page := entity.UserPage{Users: result}
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		page.Users = result[:filter.Limit]
+		last := page.Users[filter.Limit-1]
+		next := entity.NewUserCursor(filter.Sort, last, scores[last.ID])
+		page.Next = &next
+	}
+	if fuzzy {
+		page.Scores = make(map[uuid.UUID]float64, len(page.Users))
+		for _, u := range page.Users {
+			page.Scores[u.ID] = scores[u.ID]
+		}
+	}
+	return page, nil
+}
+
+func (m *UserRepositoryMock) Stream(ctx context.Context, filter entity.UserFilter, fn func(entity.User) error) error {
+	filter.Limit, filter.After = 0, nil
+	page, err := m.Search(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, u := range page.Users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *UserRepositoryMock) Suggest(ctx context.Context, prefix string, limit int) ([]entity.UserSuggestion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var byName, byEmail []entity.User
+	for _, u := range m.users {
+		if u.IsDeleted() {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(strings.ToLower(u.Name), prefix):
+			byName = append(byName, u)
+		case strings.HasPrefix(u.Email, prefix):
+			byEmail = append(byEmail, u)
+		}
+	}
+	sortByKey(byName, func(u entity.User) string { return strings.ToLower(u.Name) })
+	sortByKey(byEmail, func(u entity.User) string { return u.Email })
+	matches := append(byName, byEmail...)
+
+	var suggestions []entity.UserSuggestion
+	for _, u := range matches[:min(limit, len(matches))] {
+		suggestions = append(suggestions, entity.UserSuggestion{ID: u.ID, Name: u.Name, Email: u.Email})
+	}
+	return suggestions, nil
+}
+
+// sortByKey orders users by key, breaking ties by ID as the repositories do.
+func sortByKey(users []entity.User, key func(entity.User) string) {
+	sort.Slice(users, func(i, j int) bool {
+		if order := strings.Compare(key(users[i]), key(users[j])); order != 0 {
+			return order < 0
+		}
+		return users[i].ID.String() < users[j].ID.String()
+	})
+}
+
+// compareUsers orders a before b in the given sort, breaking ties by ID as
+// the repositories do. It returns a negative number, zero or a positive
+// number like strings.Compare. scores is only read when sorting by relevance.
+// Strings are compared byte by byte, not by the database collation, which is
+// close enough for the ASCII names used in tests.
+func compareUsers(s entity.UserSort, a, b entity.User, scores map[uuid.UUID]float64) int {
+	order := compareSortValues(s.Value(a, scores[a.ID]), s.Value(b, scores[b.ID]))
+	if order == 0 {
+		order = strings.Compare(a.ID.String(), b.ID.String())
+	}
+	if s.Descending {
+		return -order
+	}
+	return order
+}
+
+// followsCursor reports whether user, of relevance score, comes after the
+// cursor, that is whether it belongs to the pages that follow it.
+func followsCursor(c entity.UserCursor, user entity.User, score float64) bool {
+	order := compareSortValues(c.Value, c.Sort.Value(user, score))
+	if order == 0 {
+		order = strings.Compare(c.ID.String(), user.ID.String())
+	}
+	if c.Sort.Descending {
+		return order > 0
+	}
+	return order < 0
+}
+
+func compareSortValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	}
+	return 0
+}
+
+// similarityThreshold is the pg_trgm default for the % operator.
+const similarityThreshold = 0.3
+
+// similarity approximates pg_trgm's similarity(): the share of trigrams two
+// strings have in common, each word padded with two spaces in front and one
+// behind.
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+func inTimeRange(t, after, before time.Time) bool {
+	if !after.IsZero() && !t.After(after) {
+		return false
+	}
+	if !before.IsZero() && !t.Before(before) {
+		return false
+	}
+	return true
+}
+
+func (m *UserRepositoryMock) EmailExists(ctx context.Context, email string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if m.emailExistsErr != nil {
+		return false, m.emailExistsErr
+	}
+	return m.emailExist, nil
+}
+
+func (m *UserRepositoryMock) Import(
+	ctx context.Context, source entity.UserSource, dryRun bool,
+) (entity.UserImportResult, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.UserImportResult{}, err
+	}
+	taken := make(map[string]bool)
+	for _, u := range m.users {
+		if !u.IsDeleted() {
+			taken[u.Email] = true
+		}
+	}
+
+	var result entity.UserImportResult
+	imported := make(map[string]bool)
+	var created []entity.User
+	for {
+		row, err := source()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return entity.UserImportResult{}, err
+		}
+		if taken[row.User.Email] || imported[row.User.Email] {
+			result.Duplicates = append(result.Duplicates, entity.UserImportDuplicate{
+				Line: row.Line, Email: row.User.Email, Taken: taken[row.User.Email],
+			})
+			continue
+		}
+		imported[row.User.Email] = true
+		created = append(created, row.User)
+	}
+
+	result.Users = created
+	if !dryRun {
+		for _, user := range created {
+			m.users[user.ID.String()] = user
 		}
 	}
 	return result, nil
 }
 
-func (m *UserRepositoryMock) EmailExists(email string) bool {
-	return m.emailExist
+func (m *UserRepositoryMock) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var purged int64
+	for id, u := range m.users {
+		if purged == int64(limit) {
+			break
+		}
+		if u.IsDeleted() && u.DeletedAt.Before(before) {
+			delete(m.users, id)
+			purged++
+		}
+	}
+	return purged, nil
 }
@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookRepositoryMock keeps deliveries in the order they were added.
+type WebhookRepositoryMock struct {
+	subscriptions map[uuid.UUID]entity.WebhookSubscription
+	deliveries    []entity.WebhookDelivery
+}
+
+func SetupMockWebhookRepo() *WebhookRepositoryMock {
+	return &WebhookRepositoryMock{subscriptions: make(map[uuid.UUID]entity.WebhookSubscription)}
+}
+
+func (m *WebhookRepositoryMock) AddSubscription(
+	ctx context.Context, subscription entity.WebhookSubscription,
+) (entity.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	subscription.CreatedAt = time.Now()
+	m.subscriptions[subscription.ID] = subscription
+	return subscription, nil
+}
+
+func (m *WebhookRepositoryMock) GetSubscription(ctx context.Context, id uuid.UUID) (entity.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	return m.subscriptions[id], nil
+}
+
+func (m *WebhookRepositoryMock) SubscriptionsFor(
+	ctx context.Context, eventType string,
+) ([]entity.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var subscriptions []entity.WebhookSubscription
+	for _, subscription := range m.subscriptions {
+		if slices.Contains(subscription.EventTypes, eventType) {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (m *WebhookRepositoryMock) AddDeliveries(ctx context.Context, deliveries ...entity.WebhookDelivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		duplicate := slices.ContainsFunc(m.deliveries, func(d entity.WebhookDelivery) bool {
+			return d.SubscriptionID == delivery.SubscriptionID && d.EventID == delivery.EventID
+		})
+		if !duplicate {
+			m.deliveries = append(m.deliveries, delivery)
+		}
+	}
+	return nil
+}
+
+func (m *WebhookRepositoryMock) ClaimDue(
+	ctx context.Context, now time.Time, lease time.Duration, limit int,
+) ([]entity.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var due []entity.WebhookDelivery
+	for i := range m.deliveries {
+		delivery := &m.deliveries[i]
+		if len(due) == limit {
+			break
+		}
+		if delivery.Status == entity.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			delivery.NextAttemptAt = now.Add(lease)
+			due = append(due, *delivery)
+		}
+	}
+	return due, nil
+}
+
+func (m *WebhookRepositoryMock) RecordAttempt(ctx context.Context, delivery entity.WebhookDelivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == delivery.ID {
+			m.deliveries[i] = delivery
+		}
+	}
+	return nil
+}
+
+func (m *WebhookRepositoryMock) Deliveries(
+	ctx context.Context, subscriptionID uuid.UUID, limit int,
+) ([]entity.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var deliveries []entity.WebhookDelivery
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if m.deliveries[i].SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, m.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
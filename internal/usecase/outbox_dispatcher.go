@@ -0,0 +1,136 @@
+// Clean Architecture - Use Case Layer
+// Background publication of the events waiting in the outbox
+package usecase
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// dispatchBatchSize bounds how many events a dispatcher holds locked at once.
+const dispatchBatchSize = 100
+
+// OutboxDispatcher publishes the events of the outbox and marks them
+// delivered. An event is only marked once its publication succeeded, in the
+// unit of work that claimed it: events are delivered at least once, and a
+// crash between the two means a second delivery. Events that fail
+// maxAttempts times are given up on.
+type OutboxDispatcher struct {
+	uow         entity.IUnitOfWork
+	repo        entity.IOutboxRepository
+	publisher   entity.IEventPublisher
+	logger      logger.ILogger
+	interval    time.Duration
+	maxAttempts int
+	wake        chan struct{}
+}
+
+func NewOutboxDispatcher(
+	uow entity.IUnitOfWork, repo entity.IOutboxRepository, publisher entity.IEventPublisher,
+	logger logger.ILogger, interval time.Duration, maxAttempts int,
+) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		uow:         uow,
+		repo:        repo,
+		publisher:   publisher,
+		logger:      logger,
+		interval:    interval,
+		maxAttempts: maxAttempts,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Wake has Run dispatch right away rather than on its next tick, as when
+// events were just recorded. Wakes that come while Run is busy are merged.
+func (d *OutboxDispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// DispatchOnce publishes one batch of pending events, in order, and reports
+// how many were delivered. When an event cannot be published, the later
+// events of the same user are held back so that they are not delivered
+// ahead of it, while the events of other users go on. The event is tried
+// again on the next dispatch, until it has failed maxAttempts times: it is
+// then given up on, and the events held back behind it are released. The
+// events behind one that another dispatcher holds are not even claimed.
+//
+// Each event is published in a unit of work nested in the one that claimed
+// it, so that publishers writing to the database cannot, by failing, leave
+// that unit of work unable to record the outcome.
+func (d *OutboxDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	var delivered []uuid.UUID
+	err := d.uow.Do(ctx, entity.TxOptions{}, func(ctx context.Context) error {
+		delivered = delivered[:0]
+		events, err := d.repo.ClaimPending(ctx, dispatchBatchSize)
+		if err != nil {
+			return err
+		}
+
+		blocked := make(map[uuid.UUID]bool)
+		for _, event := range events {
+			if blocked[event.AggregateID] {
+				continue
+			}
+			err := d.uow.Do(ctx, entity.TxOptions{}, func(ctx context.Context) error {
+				return d.publisher.Publish(ctx, event)
+			})
+			if err != nil {
+				giveUp := event.Attempts+1 >= d.maxAttempts
+				d.logger.Error(fmt.Sprintf("Error publishing event %s: %s", event.ID, err.Error()))
+				if giveUp {
+					d.logger.Error(fmt.Sprintf("Giving up on event %s after %d attempts", event.ID, event.Attempts+1))
+				}
+				if err := d.repo.MarkFailed(ctx, event.ID, err.Error(), giveUp); err != nil {
+					return err
+				}
+				blocked[event.AggregateID] = true
+				continue
+			}
+			delivered = append(delivered, event.ID)
+		}
+		return d.repo.MarkDelivered(ctx, delivered)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(delivered), nil
+}
+
+// Run dispatches on every interval, and when woken, until ctx is done. Full
+// batches are followed by another one right away.
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+			d.dispatchAll(ctx)
+		case <-ticker.C:
+			d.dispatchAll(ctx)
+		}
+	}
+}
+
+// dispatchAll dispatches until a batch is not full.
+func (d *OutboxDispatcher) dispatchAll(ctx context.Context) {
+	for {
+		delivered, err := d.DispatchOnce(ctx)
+		if err != nil {
+			d.logger.Error(fmt.Sprintf("Error dispatching outbox events: %s", err.Error()))
+		}
+		if err != nil || delivered < dispatchBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// publisherMock records published events and fails those listed in fail.
+type publisherMock struct {
+	published []uuid.UUID
+	fail      map[uuid.UUID]bool
+}
+
+func (p *publisherMock) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	if p.fail[event.ID] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+type dispatchTestCase struct {
+	testName string
+	// users holds the user, by index, each event is about.
+	users    []int
+	fail     int
+	expected []int
+}
+
+func TestOutboxDispatcher_DispatchOnce(t *testing.T) {
+	tests_scenarios := []dispatchTestCase{
+		{testName: "Every Event Delivered", users: []int{0, 0, 0}, fail: -1, expected: []int{0, 1, 2}},
+		{testName: "Holds Back Later Events Of The User", users: []int{0, 0, 0}, fail: 1, expected: []int{0}},
+		{testName: "Delivers Events Of Other Users", users: []int{0, 1, 0, 1}, fail: 0, expected: []int{1, 3}},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			repo := SetupMockOutboxRepo()
+			users := []entity.User{
+				{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"},
+				{ID: uuid.New(), Name: "Jane Doe", Email: "jane@example.com"},
+			}
+			for _, i := range tt.users {
+				repo.events = append(repo.events, entity.NewUserEvent(entity.UserUpdated, &users[i], &users[i]))
+			}
+			publisher := &publisherMock{fail: make(map[uuid.UUID]bool)}
+			if tt.fail >= 0 {
+				publisher.fail[repo.events[tt.fail].ID] = true
+			}
+
+			dispatcher := NewOutboxDispatcher(SetupMockUnitOfWork(), repo, publisher, logger.NewLogger(), time.Hour, 3)
+			delivered, err := dispatcher.DispatchOnce(context.Background())
+
+			assert.NoError(t, err, "should not return an error")
+			assert.Equal(t, len(tt.expected), delivered, "should report the delivered events")
+			assert.Len(t, repo.delivered, len(tt.expected), "should mark the delivered events")
+			expected := make([]uuid.UUID, 0, len(tt.expected))
+			for _, i := range tt.expected {
+				expected = append(expected, repo.events[i].ID)
+			}
+			assert.Equal(t, expected, publisher.published, "should publish events in order")
+			if tt.fail >= 0 {
+				failed := repo.events[tt.fail]
+				assert.Equal(t, 1, failed.Attempts, "should count the failed attempt")
+				assert.Equal(t, "broker unavailable", repo.failures[failed.ID])
+				assert.False(t, repo.dead[failed.ID], "should try the event again")
+			}
+		})
+	}
+}
+
+func TestOutboxDispatcher_GivesUpOnPoisonEvent(t *testing.T) {
+	repo := SetupMockOutboxRepo()
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	poison := entity.NewUserEvent(entity.UserCreated, nil, &user)
+	next := entity.NewUserEvent(entity.UserUpdated, &user, &user)
+	repo.events = append(repo.events, poison, next)
+	publisher := &publisherMock{fail: map[uuid.UUID]bool{poison.ID: true}}
+
+	const maxAttempts = 3
+	dispatcher := NewOutboxDispatcher(
+		SetupMockUnitOfWork(), repo, publisher, logger.NewLogger(), time.Hour, maxAttempts,
+	)
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		delivered, err := dispatcher.DispatchOnce(context.Background())
+		assert.NoError(t, err, "should not return an error")
+		assert.Zero(t, delivered, "should hold back the events behind a failing one")
+		assert.Equal(t, attempt == maxAttempts, repo.dead[poison.ID], "should only give up after the last attempt")
+	}
+
+	delivered, err := dispatcher.DispatchOnce(context.Background())
+	assert.NoError(t, err, "should not return an error")
+	assert.Equal(t, 1, delivered, "should release the events behind an event given up on")
+	assert.Equal(t, []uuid.UUID{next.ID}, publisher.published)
+	assert.Equal(t, maxAttempts, repo.events[0].Attempts, "should not try an event given up on again")
+}
+
+// errTxAborted is what the database answers in a transaction once one of
+// its statements failed, until it is rolled back.
+var errTxAborted = errors.New("current transaction is aborted")
+
+type txMockKey struct{}
+
+// txMock is a database transaction, aborted by its first failed statement.
+type txMock struct {
+	aborted bool
+}
+
+// execInTx runs a statement in the transaction of ctx, which fails when
+// fails is set.
+func execInTx(ctx context.Context, fails bool) error {
+	tx, _ := ctx.Value(txMockKey{}).(*txMock)
+	if tx == nil {
+		return nil
+	}
+	if tx.aborted {
+		return errTxAborted
+	}
+	if fails {
+		tx.aborted = true
+		return errors.New(`relation "webhooks" does not exist`)
+	}
+	return nil
+}
+
+// savepointUnitOfWorkMock runs units of work in a txMock, and nests units of
+// work in savepoints of it: a failed nested unit of work is rolled back and
+// leaves the transaction usable.
+type savepointUnitOfWorkMock struct{}
+
+func (savepointUnitOfWorkMock) Do(
+	ctx context.Context, opts entity.TxOptions, fn func(ctx context.Context) error,
+) error {
+	if err := execInTx(ctx, false); err != nil {
+		return err
+	}
+	return fn(context.WithValue(ctx, txMockKey{}, &txMock{}))
+}
+
+// txOutboxRepositoryMock records the outcome of publications with
+// statements in the transaction of ctx.
+type txOutboxRepositoryMock struct {
+	*OutboxRepositoryMock
+}
+
+func (m txOutboxRepositoryMock) MarkDelivered(ctx context.Context, ids []uuid.UUID) error {
+	if err := execInTx(ctx, false); err != nil {
+		return err
+	}
+	return m.OutboxRepositoryMock.MarkDelivered(ctx, ids)
+}
+
+func (m txOutboxRepositoryMock) MarkFailed(ctx context.Context, id uuid.UUID, reason string, giveUp bool) error {
+	if err := execInTx(ctx, false); err != nil {
+		return err
+	}
+	return m.OutboxRepositoryMock.MarkFailed(ctx, id, reason, giveUp)
+}
+
+// sqlPublisherMock publishes with a statement in the transaction of ctx,
+// which fails for the events listed in fail.
+type sqlPublisherMock struct {
+	fail map[uuid.UUID]bool
+}
+
+func (p *sqlPublisherMock) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	return execInTx(ctx, p.fail[event.ID])
+}
+
+func TestOutboxDispatcher_PublisherSQLFailure(t *testing.T) {
+	repo := SetupMockOutboxRepo()
+	john := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	jane := entity.User{ID: uuid.New(), Name: "Jane Doe", Email: "jane@example.com"}
+	failing := entity.NewUserEvent(entity.UserCreated, nil, &john)
+	repo.events = append(repo.events, failing, entity.NewUserEvent(entity.UserCreated, nil, &jane))
+	publisher := &sqlPublisherMock{fail: map[uuid.UUID]bool{failing.ID: true}}
+
+	dispatcher := NewOutboxDispatcher(
+		savepointUnitOfWorkMock{}, txOutboxRepositoryMock{repo}, publisher, logger.NewLogger(), time.Hour, 3,
+	)
+	delivered, err := dispatcher.DispatchOnce(context.Background())
+
+	assert.NoError(t, err, "should record the outcome after a publisher statement failed")
+	assert.Equal(t, 1, delivered, "should deliver the events of other users")
+	assert.Equal(t, 1, repo.events[0].Attempts, "should count the failed attempt")
+	assert.Equal(t, `relation "webhooks" does not exist`, repo.failures[failing.ID])
+}
+
+// signalingPublisher reports every published event on published.
+type signalingPublisher struct {
+	published chan uuid.UUID
+}
+
+func (p *signalingPublisher) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	p.published <- event.ID
+	return nil
+}
+
+func TestOutboxDispatcher_WakeDispatchesRightAway(t *testing.T) {
+	repo := SetupMockOutboxRepo()
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	event := entity.NewUserEvent(entity.UserCreated, nil, &user)
+	repo.events = append(repo.events, event)
+	publisher := &signalingPublisher{published: make(chan uuid.UUID, 1)}
+
+	dispatcher := NewOutboxDispatcher(SetupMockUnitOfWork(), repo, publisher, logger.NewLogger(), time.Hour, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+	dispatcher.Wake()
+
+	select {
+	case id := <-publisher.published:
+		assert.Equal(t, event.ID, id, "should publish pending events when woken")
+	case <-time.After(time.Second):
+		t.Fatal("dispatcher did not dispatch when woken")
+	}
+}
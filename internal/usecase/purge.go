@@ -0,0 +1,58 @@
+// Clean Architecture - Use Case Layer
+// Periodic purge of rows that are no longer needed
+package usecase
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"context"
+	"fmt"
+	"time"
+)
+
+// purgeBatchSize bounds how many rows a single purge statement removes so
+// a large backlog does not hold locks for long.
+const purgeBatchSize = 500
+
+// purgeInBatches calls purge with purgeBatchSize until a batch removes
+// fewer rows, and reports how many were removed in all.
+func purgeInBatches(
+	ctx context.Context, purge func(ctx context.Context, limit int) (int64, error),
+) (int64, error) {
+	var total int64
+	for {
+		purged, err := purge(ctx, purgeBatchSize)
+		if err != nil {
+			return total, err
+		}
+		total += purged
+		if purged < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// runPurges calls purgeOnce on every interval until ctx is done, logging
+// how many rows, described by what, were removed.
+func runPurges(
+	ctx context.Context, logger logger.ILogger, interval time.Duration, what string,
+	purgeOnce func(ctx context.Context) (int64, error),
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := purgeOnce(ctx)
+			if err != nil {
+				logger.Error(fmt.Sprintf("Error purging %s: %s", what, err.Error()))
+				continue
+			}
+			if purged > 0 {
+				logger.Info(fmt.Sprintf("Purged %d %s", purged, what))
+			}
+		}
+	}
+}
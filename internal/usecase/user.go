@@ -3,84 +3,520 @@
 package usecase
 
 import (
+	"clean-go-rest-api/internal/domain/domainerr"
 	"clean-go-rest-api/internal/domain/dto"
 	"clean-go-rest-api/internal/domain/entity"
+	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type IUserUseCase interface {
-	Add(req dto.CreateUserRequest) (uuid.UUID, error)
-	Delete(req dto.DeleteUserRequest) error
-	Update(req dto.UpdateUserRequest) error
-	GetById(id uuid.UUID) (entity.User, error)
-	Search(name string) ([]entity.User, error)
+	Add(ctx context.Context, req dto.CreateUserRequest) (uuid.UUID, error)
+	AddBatch(ctx context.Context, req dto.CreateUsersRequest) ([]dto.CreateUserResult, error)
+	Import(ctx context.Context, req dto.ImportUsersRequest) (dto.ImportUsersReport, error)
+	Delete(ctx context.Context, req dto.DeleteUserRequest) error
+	Update(ctx context.Context, req dto.UpdateUserRequest) (entity.User, error)
+	Patch(ctx context.Context, req dto.PatchUserRequest) (entity.User, error)
+	Restore(ctx context.Context, req dto.RestoreUserRequest) (entity.User, error)
+	GetById(ctx context.Context, id uuid.UUID) (entity.User, error)
+	GetByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (entity.User, error)
+	Search(ctx context.Context, req dto.SearchUsersRequest) (entity.UserPage, error)
+	Export(ctx context.Context, req dto.SearchUsersRequest, fn func(entity.User) error) error
+	Suggest(ctx context.Context, req dto.SuggestUsersRequest) ([]entity.UserSuggestion, error)
 }
 
+const (
+	// DefaultSearchLimit is the page size of searches that do not ask for one.
+	DefaultSearchLimit = 20
+	// MaxSearchLimit caps the page size of searches.
+	MaxSearchLimit = 100
+
+	// DefaultSuggestLimit is the number of suggestions offered by default.
+	DefaultSuggestLimit = 10
+	// MaxSuggestLimit caps the number of suggestions.
+	MaxSuggestLimit = 25
+
+	// MaxBatchSize caps the number of users created by one AddBatch.
+	MaxBatchSize = 1000
+)
+
+// ErrBatchAborted is reported for the valid users of an all-or-nothing batch
+// that were not created because another user of the batch was rejected.
+var ErrBatchAborted = errors.New("not created, another user of the batch was rejected")
+
 type UserUseCase struct {
-	repo entity.IUserRepository
+	repo   entity.IUserRepository
+	uow    entity.IUnitOfWork
+	outbox entity.IOutboxRepository
 }
 
-func NewUserUseCase(repo entity.IUserRepository) IUserUseCase {
-	return &UserUseCase{repo: repo}
+// NewUserUseCase runs every change in a unit of work of uow, so that changes
+// that read a user before writing it see a consistent state, and so that the
+// events recorded in outbox are stored if and only if the change is.
+func NewUserUseCase(
+	repo entity.IUserRepository, uow entity.IUnitOfWork, outbox entity.IOutboxRepository,
+) IUserUseCase {
+	return &UserUseCase{repo: repo, uow: uow, outbox: outbox}
 }
 
-func (u *UserUseCase) Add(req dto.CreateUserRequest) (uuid.UUID, error) {
-	if u.repo.EmailExists(req.Email) {
-		return uuid.Nil, errors.New("user already exists")
+// Add leaves duplicate emails to the repository, which rejects them with a
+// conflict atomically; checking beforehand would race with other signups.
+func (u *UserUseCase) Add(ctx context.Context, req dto.CreateUserRequest) (uuid.UUID, error) {
+	user, err := entity.NewUser(uuid.New(), req.Name, req.Email)
+	if err != nil {
+		return uuid.Nil, err
 	}
 
-	user := entity.User{
-		ID:    uuid.New(),
-		Name:  req.Name,
-		Email: req.Email,
-	}
-	if err := u.repo.Add(user); err != nil {
+	err = u.uow.Do(ctx, entity.TxOptions{}, func(ctx context.Context) error {
+		created, err := u.repo.Add(ctx, user)
+		if err != nil {
+			return err
+		}
+		return u.outbox.Add(ctx, entity.NewUserEvent(entity.UserCreated, nil, &created))
+	})
+	if err != nil {
 		return uuid.Nil, err
 	}
 
 	return user.ID, nil
 }
 
-func (u *UserUseCase) Delete(req dto.DeleteUserRequest) error {
-	user, err := u.repo.GetById(req.ID)
+// AddBatch validates every user on its own, reporting one result per user in
+// request order. Errors are only returned for the batch as a whole.
+func (u *UserUseCase) AddBatch(ctx context.Context, req dto.CreateUsersRequest) ([]dto.CreateUserResult, error) {
+	if len(req.Users) == 0 || len(req.Users) > MaxBatchSize {
+		return nil, domainerr.Validation(
+			fmt.Sprintf("a batch must hold between 1 and %d users", MaxBatchSize),
+		)
+	}
+
+	results := make([]dto.CreateUserResult, len(req.Users))
+	users := make([]entity.User, 0, len(req.Users))
+	positions := make(map[uuid.UUID]int, len(req.Users))
+	emails := make(map[string]bool, len(req.Users))
+	rejected := false
+	for i, item := range req.Users {
+		user, err := entity.NewUser(uuid.New(), item.Name, item.Email)
+		if err == nil && emails[user.Email] {
+			err = domainerr.Conflict("email already used earlier in the batch")
+		}
+		if err != nil {
+			results[i].Err = err
+			rejected = true
+			continue
+		}
+		emails[user.Email] = true
+		positions[user.ID] = i
+		users = append(users, user)
+	}
+
+	var conflicts map[uuid.UUID]bool
+	if len(users) > 0 && (req.BestEffort || !rejected) {
+		err := u.uow.Do(ctx, entity.TxOptions{}, func(ctx context.Context) error {
+			inserted, c, err := u.repo.AddMany(ctx, users, req.BestEffort)
+			if err != nil {
+				return err
+			}
+			conflicts = c
+			events := make([]entity.OutboxEvent, 0, len(inserted))
+			for i := range inserted {
+				events = append(events, entity.NewUserEvent(entity.UserCreated, nil, &inserted[i]))
+			}
+			return u.outbox.Add(ctx, events...)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	aborted := !req.BestEffort && (rejected || len(conflicts) > 0)
+	for _, user := range users {
+		result := &results[positions[user.ID]]
+		switch {
+		case conflicts[user.ID]:
+			result.Err = domainerr.Conflict("user already exists")
+		case aborted:
+			result.Err = ErrBatchAborted
+		default:
+			result.ID = user.ID
+		}
+	}
+	return results, nil
+}
+
+func (u *UserUseCase) Delete(ctx context.Context, req dto.DeleteUserRequest) error {
+	return u.uow.Do(ctx, entity.TxOptions{}, func(ctx context.Context) error {
+		return u.delete(ctx, req)
+	})
+}
+
+func (u *UserUseCase) delete(ctx context.Context, req dto.DeleteUserRequest) error {
+	user, err := u.repo.GetById(ctx, req.ID)
 	if err != nil {
 		return err
 	}
 
 	if user.ID == uuid.Nil {
-		return errors.New("user not found")
+		return domainerr.NotFound("user not found")
 	}
 
-	return u.repo.Delete(user)
+	before := user
+	expectVersion(&user, req.Versions)
+	if err := u.repo.Delete(ctx, user); err != nil {
+		return err
+	}
+	return u.outbox.Add(ctx, entity.NewUserEvent(entity.UserDeleted, &before, nil))
 }
 
-func (u *UserUseCase) Update(req dto.UpdateUserRequest) error {
-	user, err := u.repo.GetById(req.ID)
+func (u *UserUseCase) Update(ctx context.Context, req dto.UpdateUserRequest) (entity.User, error) {
+	return u.inUnitOfWork(ctx, func(ctx context.Context) (entity.User, error) {
+		return u.update(ctx, req)
+	})
+}
+
+func (u *UserUseCase) update(ctx context.Context, req dto.UpdateUserRequest) (entity.User, error) {
+	user, err := u.repo.GetById(ctx, req.ID)
 	if err != nil {
-		return err
+		return entity.User{}, err
+	}
+
+	if user.ID == uuid.Nil {
+		return entity.User{}, domainerr.NotFound("user not found")
+	}
+
+	before := user
+	if err := u.change(ctx, &user, req.Name, req.Email); err != nil {
+		return entity.User{}, err
+	}
+
+	expectVersion(&user, req.Versions)
+	return u.save(ctx, before, user)
+}
+
+func (u *UserUseCase) Patch(ctx context.Context, req dto.PatchUserRequest) (entity.User, error) {
+	return u.inUnitOfWork(ctx, func(ctx context.Context) (entity.User, error) {
+		return u.patch(ctx, req)
+	})
+}
+
+func (u *UserUseCase) patch(ctx context.Context, req dto.PatchUserRequest) (entity.User, error) {
+	user, err := u.repo.GetById(ctx, req.ID)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if user.ID == uuid.Nil {
+		return entity.User{}, domainerr.NotFound("user not found")
+	}
+
+	name, email := user.Name, user.Email
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if req.Email != nil {
+		email = *req.Email
+	}
+
+	before := user
+	if err := u.change(ctx, &user, name, email); err != nil {
+		return entity.User{}, err
+	}
+
+	// A patch that changes nothing is not written: the version stays and no
+	// event is recorded. The version the client based it on must still match.
+	if user.Name == before.Name && user.Email == before.Email {
+		if len(req.Versions) != 0 && !slices.Contains(req.Versions, before.Version) {
+			return entity.User{}, domainerr.PreconditionFailed("user was modified by another request")
+		}
+		return before, nil
+	}
+
+	expectVersion(&user, req.Versions)
+	return u.save(ctx, before, user)
+}
+
+func (u *UserUseCase) Restore(ctx context.Context, req dto.RestoreUserRequest) (entity.User, error) {
+	return u.inUnitOfWork(ctx, func(ctx context.Context) (entity.User, error) {
+		return u.restore(ctx, req)
+	})
+}
+
+func (u *UserUseCase) restore(ctx context.Context, req dto.RestoreUserRequest) (entity.User, error) {
+	user, err := u.repo.GetByIdIncludingDeleted(ctx, req.ID)
+	if err != nil {
+		return entity.User{}, err
 	}
 
 	if user.ID == uuid.Nil {
-		return errors.New("user not found")
+		return entity.User{}, domainerr.NotFound("user not found")
 	}
 
-	user.Name = req.Name
-	user.Email = req.Email
+	if !user.IsDeleted() {
+		return entity.User{}, domainerr.Conflict("user is not deleted")
+	}
+
+	if err := u.checkEmailFree(ctx, user.Email); err != nil {
+		return entity.User{}, err
+	}
 
-	return u.repo.Update(user)
+	before := user
+	expectVersion(&user, req.Versions)
+	restored, err := u.repo.Restore(ctx, user)
+	if err != nil {
+		return entity.User{}, err
+	}
+	return restored, u.outbox.Add(ctx, entity.NewUserEvent(entity.UserUpdated, &before, &restored))
 }
 
-func (u *UserUseCase) GetById(id uuid.UUID) (entity.User, error) {
-	user, err := u.repo.GetById(id)
+// save updates user, which was before until changed, and records the
+// change in the outbox.
+func (u *UserUseCase) save(ctx context.Context, before, user entity.User) (entity.User, error) {
+	updated, err := u.repo.Update(ctx, user)
 	if err != nil {
 		return entity.User{}, err
 	}
+	return updated, u.outbox.Add(ctx, entity.NewUserEvent(entity.UserUpdated, &before, &updated))
+}
+
+// inUnitOfWork runs fn in a unit of work and returns the user it produced.
+func (u *UserUseCase) inUnitOfWork(
+	ctx context.Context, fn func(ctx context.Context) (entity.User, error),
+) (entity.User, error) {
+	var user entity.User
+	err := u.uow.Do(ctx, entity.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		user, err = fn(ctx)
+		return err
+	})
+	return user, err
+}
+
+// change validates and applies the new values, making sure a new email
+// does not already belong to another user.
+func (u *UserUseCase) change(ctx context.Context, user *entity.User, name, email string) error {
+	previousEmail := user.Email
+	if err := user.Change(name, email); err != nil {
+		return err
+	}
+
+	if user.Email != previousEmail {
+		return u.checkEmailFree(ctx, user.Email)
+	}
+	return nil
+}
+
+// checkEmailFree fails with a conflict when email belongs to another user.
+// When that cannot be told, the error is returned as is rather than assuming
+// the email is free: the repository reports an unreachable database as
+// unavailable, and an aborted transaction is retried by the unit of work.
+func (u *UserUseCase) checkEmailFree(ctx context.Context, email string) error {
+	exists, err := u.repo.EmailExists(ctx, email)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return domainerr.Conflict("email already in use by another user")
+	}
+	return nil
+}
+
+// expectVersion pins a write to the version the client based it on, one of
+// versions. The repository enforces it atomically; when the loaded version
+// is among them, or none was sent, it is kept, which still rejects writes
+// racing with this one. Otherwise a version the client sent is pinned, so
+// the write is rejected as stale.
+func expectVersion(user *entity.User, versions []int64) {
+	if len(versions) != 0 && !slices.Contains(versions, user.Version) {
+		user.Version = versions[0]
+	}
+}
+
+func (u *UserUseCase) GetById(ctx context.Context, id uuid.UUID) (entity.User, error) {
+	user, err := u.repo.GetById(ctx, id)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if user.ID == uuid.Nil {
+		return entity.User{}, domainerr.NotFound("user not found")
+	}
+
+	return user, nil
+}
+
+func (u *UserUseCase) GetByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (entity.User, error) {
+	user, err := u.repo.GetByIdIncludingDeleted(ctx, id)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if user.ID == uuid.Nil {
+		return entity.User{}, domainerr.NotFound("user not found")
+	}
 
 	return user, nil
 }
 
-func (u *UserUseCase) Search(name string) ([]entity.User, error) {
-	return u.repo.Search(name)
+func (u *UserUseCase) Search(ctx context.Context, req dto.SearchUsersRequest) (entity.UserPage, error) {
+	filter, err := newUserFilter(req)
+	if err != nil {
+		return entity.UserPage{}, err
+	}
+
+	return u.repo.Search(ctx, filter)
+}
+
+// Export hands every user matching req to fn, without paginating: Limit and
+// Cursor are not allowed.
+func (u *UserUseCase) Export(ctx context.Context, req dto.SearchUsersRequest, fn func(entity.User) error) error {
+	var fields []domainerr.FieldError
+	if req.Limit != "" {
+		fields = append(fields, domainerr.FieldError{Field: "limit", Message: "is not supported by exports"})
+	}
+	if req.Cursor != "" {
+		fields = append(fields, domainerr.FieldError{Field: "cursor", Message: "is not supported by exports"})
+	}
+	if len(fields) > 0 {
+		return domainerr.Validation("invalid export", fields...)
+	}
+
+	filter, err := newUserFilter(req)
+	if err != nil {
+		return err
+	}
+
+	return u.repo.Stream(ctx, filter, fn)
+}
+
+func (u *UserUseCase) Suggest(ctx context.Context, req dto.SuggestUsersRequest) ([]entity.UserSuggestion, error) {
+	prefix := strings.ToLower(strings.TrimSpace(req.Prefix))
+	var fields []domainerr.FieldError
+	if prefix == "" {
+		fields = append(fields, domainerr.FieldError{Field: "prefix", Message: "is required"})
+	}
+
+	limit := DefaultSuggestLimit
+	if req.Limit != "" {
+		var err error
+		limit, err = strconv.Atoi(req.Limit)
+		if err != nil || limit < 1 || limit > MaxSuggestLimit {
+			fields = append(fields, domainerr.FieldError{
+				Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", MaxSuggestLimit),
+			})
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, domainerr.Validation("invalid suggestion request", fields...)
+	}
+	return u.repo.Suggest(ctx, prefix, limit)
+}
+
+// newUserFilter parses and checks the raw search parameters, reporting
+// every invalid one at once.
+func newUserFilter(req dto.SearchUsersRequest) (entity.UserFilter, error) {
+	filter := entity.UserFilter{
+		Query:          strings.TrimSpace(req.Query),
+		Name:           strings.TrimSpace(req.Name),
+		Email:          entity.NormalizeUserEmail(req.Email),
+		EmailDomain:    strings.TrimPrefix(entity.NormalizeUserEmail(req.EmailDomain), "@"),
+		IncludeDeleted: req.IncludeDeleted,
+	}
+	var fields []domainerr.FieldError
+
+	mode, ok := entity.ParseUserSearchMode(req.Mode)
+	if !ok {
+		fields = append(fields, domainerr.FieldError{Field: "mode", Message: "must be contains or fuzzy"})
+	}
+	filter.Mode = mode
+	switch {
+	case mode == entity.UserSearchFuzzy && filter.Query == "":
+		fields = append(fields, domainerr.FieldError{Field: "q", Message: "is required in fuzzy mode"})
+	case mode != entity.UserSearchFuzzy && filter.Query != "":
+		fields = append(fields, domainerr.FieldError{Field: "q", Message: "is only used in fuzzy mode"})
+	}
+
+	if strings.Contains(filter.EmailDomain, "@") {
+		fields = append(fields, domainerr.FieldError{
+			Field: "email_domain", Message: "must be a domain such as example.com",
+		})
+	}
+
+	parseTime := func(field, value string, target *time.Time) {
+		if value == "" {
+			return
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			fields = append(fields, domainerr.FieldError{
+				Field: field, Message: "must be an RFC 3339 timestamp",
+			})
+			return
+		}
+		*target = parsed
+	}
+	parseTime("created_after", req.CreatedAfter, &filter.CreatedAfter)
+	parseTime("created_before", req.CreatedBefore, &filter.CreatedBefore)
+	parseTime("updated_after", req.UpdatedAfter, &filter.UpdatedAfter)
+	parseTime("updated_before", req.UpdatedBefore, &filter.UpdatedBefore)
+
+	if !filter.CreatedAfter.IsZero() && !filter.CreatedBefore.IsZero() &&
+		!filter.CreatedAfter.Before(filter.CreatedBefore) {
+		fields = append(fields, domainerr.FieldError{
+			Field: "created_after", Message: "must be before created_before",
+		})
+	}
+	if !filter.UpdatedAfter.IsZero() && !filter.UpdatedBefore.IsZero() &&
+		!filter.UpdatedAfter.Before(filter.UpdatedBefore) {
+		fields = append(fields, domainerr.FieldError{
+			Field: "updated_after", Message: "must be before updated_before",
+		})
+	}
+
+	sort, ok := entity.ParseUserSort(req.Sort)
+	switch {
+	case !ok:
+		fields = append(fields, domainerr.FieldError{Field: "sort", Message: "is not a sortable field"})
+	case mode == entity.UserSearchFuzzy && strings.TrimSpace(req.Sort) == "":
+		sort = entity.RelevanceUserSort
+	case mode != entity.UserSearchFuzzy && sort.Field == entity.UserSortByRelevance:
+		ok = false
+		fields = append(fields, domainerr.FieldError{Field: "sort", Message: "relevance is only known in fuzzy mode"})
+	}
+	filter.Sort = sort
+
+	filter.Limit = DefaultSearchLimit
+	if req.Limit != "" {
+		limit, err := strconv.Atoi(req.Limit)
+		if err != nil || limit < 1 || limit > MaxSearchLimit {
+			fields = append(fields, domainerr.FieldError{
+				Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", MaxSearchLimit),
+			})
+		}
+		filter.Limit = limit
+	}
+
+	if req.Cursor != "" {
+		cursor, err := entity.DecodeUserCursor(req.Cursor)
+		switch {
+		case err != nil:
+			fields = append(fields, domainerr.FieldError{Field: "cursor", Message: "is not a valid cursor"})
+		case ok && cursor.Sort != sort:
+			// A cursor only makes sense in the order it was issued for.
+			fields = append(fields, domainerr.FieldError{Field: "cursor", Message: "does not match sort"})
+		}
+		filter.After = &cursor
+	}
+
+	if len(fields) > 0 {
+		return entity.UserFilter{}, domainerr.Validation("invalid search", fields...)
+	}
+	return filter, nil
 }
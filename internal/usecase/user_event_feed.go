@@ -0,0 +1,193 @@
+// Clean Architecture - Use Case Layer
+// In-process feed of user events for live subscribers
+package usecase
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// UserEventReplaySize is how many of the latest events a feed keeps for
+	// subscribers resuming after a disconnection.
+	UserEventReplaySize = 1000
+
+	// feedSubscriberBuffer is how many events a subscriber may lag behind
+	// before it is dropped.
+	feedSubscriberBuffer = 64
+)
+
+// IUserEventFeed hands out live user events. Subscribe returns the buffered
+// events that followed lastEventID, then delivers new ones on events until
+// cancel is called. events is closed when the feed is closed, when the
+// subscriber falls too far behind or when the feed itself missed events; it
+// should then resume from the last event it got. Neither uuid.Nil nor a
+// lastEventID the feed does not remember replays anything: the events that
+// followed it cannot be told apart from those the subscriber already got.
+type IUserEventFeed interface {
+	Subscribe(
+		lastEventID uuid.UUID,
+	) (replay []entity.OutboxEvent, events <-chan entity.OutboxEvent, cancel func())
+}
+
+// UserEventFeed keeps the latest events in a bounded buffer and fans them
+// out to its subscribers. It follows the events published by the outbox of
+// every instance, so it only ever announces committed changes, whichever
+// instance made them.
+type UserEventFeed struct {
+	repo   entity.IOutboxRepository
+	logger logger.ILogger
+
+	mu          sync.Mutex
+	buffer      []entity.OutboxEvent
+	buffered    map[uuid.UUID]bool
+	size        int
+	subscribers map[chan entity.OutboxEvent]struct{}
+	closed      bool
+}
+
+// NewUserEventFeed remembers the last size events. repo is where the feed
+// reads back the latest events after missing some.
+func NewUserEventFeed(repo entity.IOutboxRepository, logger logger.ILogger, size int) *UserEventFeed {
+	return &UserEventFeed{
+		repo:        repo,
+		logger:      logger,
+		buffer:      make([]entity.OutboxEvent, 0, size),
+		buffered:    make(map[uuid.UUID]bool, size),
+		size:        size,
+		subscribers: make(map[chan entity.OutboxEvent]struct{}),
+	}
+}
+
+// Follow publishes the events announced on changes until ctx is done or
+// changes is closed. It first, and again whenever changes were missed,
+// resyncs with the outbox.
+func (f *UserEventFeed) Follow(ctx context.Context, changes <-chan entity.UserChange) {
+	f.resync(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			switch {
+			case change.Type == entity.UserChangesMissed:
+				f.resync(ctx)
+			case change.Event != nil:
+				f.Publish(*change.Event)
+			}
+		}
+	}
+}
+
+// resync replaces the buffer with the latest events delivered, and ends
+// every subscription: subscribers that missed events resume from the last
+// one they got, now found in the buffer. On failure the buffer is kept, as
+// it is still right up to the events missed.
+func (f *UserEventFeed) resync(ctx context.Context) {
+	events, err := f.repo.LatestDelivered(ctx, f.size)
+	if err != nil {
+		if ctx.Err() == nil {
+			f.logger.Error(fmt.Sprintf("Error reloading the latest user events: %s", err.Error()))
+		}
+		f.endSubscriptions()
+		return
+	}
+
+	f.mu.Lock()
+	f.buffer = f.buffer[:0]
+	clear(f.buffered)
+	for _, event := range events {
+		f.buffer = append(f.buffer, event)
+		f.buffered[event.ID] = true
+	}
+	f.mu.Unlock()
+	f.endSubscriptions()
+}
+
+func (f *UserEventFeed) endSubscriptions() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for subscriber := range f.subscribers {
+		delete(f.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// Publish never blocks on subscribers: those that cannot take the event
+// right away are dropped. Events already buffered, as when published again
+// by the outbox or reloaded by a resync, are ignored.
+func (f *UserEventFeed) Publish(event entity.OutboxEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed || f.buffered[event.ID] {
+		return
+	}
+
+	if len(f.buffer) == f.size {
+		delete(f.buffered, f.buffer[0].ID)
+		copy(f.buffer, f.buffer[1:])
+		f.buffer = f.buffer[:f.size-1]
+	}
+	f.buffer = append(f.buffer, event)
+	f.buffered[event.ID] = true
+
+	for subscriber := range f.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			delete(f.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+func (f *UserEventFeed) Subscribe(
+	lastEventID uuid.UUID,
+) ([]entity.OutboxEvent, <-chan entity.OutboxEvent, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var replay []entity.OutboxEvent
+	if f.buffered[lastEventID] {
+		for i, event := range f.buffer {
+			if event.ID == lastEventID {
+				replay = append(replay, f.buffer[i+1:]...)
+				break
+			}
+		}
+	}
+
+	subscriber := make(chan entity.OutboxEvent, feedSubscriberBuffer)
+	if f.closed {
+		close(subscriber)
+		return replay, subscriber, func() {}
+	}
+	f.subscribers[subscriber] = struct{}{}
+
+	cancel := func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subscribers[subscriber]; ok {
+			delete(f.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+	return replay, subscriber, cancel
+}
+
+// Close ends every subscription, for the server to shut down without
+// waiting on them. Later subscriptions end right away.
+func (f *UserEventFeed) Close() {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	f.endSubscriptions()
+}
@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type feedReplayTestCase struct {
+	testName    string
+	lastEventID func(events []entity.OutboxEvent) uuid.UUID
+	expected    func(events []entity.OutboxEvent) []entity.OutboxEvent
+}
+
+func TestUserEventFeed_SubscribeReplay(t *testing.T) {
+	tests_scenarios := []feedReplayTestCase{
+		{
+			testName:    "No Last Event",
+			lastEventID: func([]entity.OutboxEvent) uuid.UUID { return uuid.Nil },
+			expected:    func([]entity.OutboxEvent) []entity.OutboxEvent { return nil },
+		},
+		{
+			testName:    "Resume After Buffered Event",
+			lastEventID: func(events []entity.OutboxEvent) uuid.UUID { return events[2].ID },
+			expected:    func(events []entity.OutboxEvent) []entity.OutboxEvent { return events[3:] },
+		},
+		{
+			testName:    "Resume After Latest Event",
+			lastEventID: func(events []entity.OutboxEvent) uuid.UUID { return events[4].ID },
+			expected:    func([]entity.OutboxEvent) []entity.OutboxEvent { return nil },
+		},
+		{
+			testName:    "Last Event Out Of The Buffer",
+			lastEventID: func(events []entity.OutboxEvent) uuid.UUID { return events[0].ID },
+			expected:    func([]entity.OutboxEvent) []entity.OutboxEvent { return nil },
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			feed := NewUserEventFeed(SetupMockOutboxRepo(), logger.NewLogger(), 4)
+			user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+			var events []entity.OutboxEvent
+			for i := 0; i < 5; i++ {
+				event := entity.NewUserEvent(entity.UserUpdated, &user, &user)
+				events = append(events, event)
+				feed.Publish(event)
+			}
+
+			replay, _, cancel := feed.Subscribe(tt.lastEventID(events))
+			defer cancel()
+
+			assert.Equal(t, tt.expected(events), replay)
+		})
+	}
+}
+
+func TestUserEventFeed_Publish(t *testing.T) {
+	feed := NewUserEventFeed(SetupMockOutboxRepo(), logger.NewLogger(), UserEventReplaySize)
+	_, live, cancelLive := feed.Subscribe(uuid.Nil)
+	defer cancelLive()
+	_, lagging, cancelLagging := feed.Subscribe(uuid.Nil)
+	defer cancelLagging()
+
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	for i := 0; i < feedSubscriberBuffer+1; i++ {
+		event := entity.NewUserEvent(entity.UserCreated, nil, &user)
+		feed.Publish(event)
+		if i < feedSubscriberBuffer {
+			assert.Equal(t, event, <-live, "should deliver events to subscribers")
+		}
+	}
+
+	received := 0
+	for range lagging {
+		received++
+	}
+	assert.Equal(t, feedSubscriberBuffer, received, "should drop subscribers that fall behind")
+	_, open := <-live
+	assert.True(t, open, "should keep subscribers that keep up")
+
+	feed.Close()
+	_, open = <-live
+	assert.False(t, open, "should end subscriptions once closed")
+	_, events, _ := feed.Subscribe(uuid.Nil)
+	_, open = <-events
+	assert.False(t, open, "should end subscriptions made after closing")
+}
+
+func TestUserEventFeed_Follow(t *testing.T) {
+	outbox := SetupMockOutboxRepo()
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	delivered := entity.NewUserEvent(entity.UserCreated, nil, &user)
+	outbox.events = append(outbox.events, delivered)
+	outbox.delivered[delivered.ID] = true
+
+	feed := NewUserEventFeed(outbox, logger.NewLogger(), UserEventReplaySize)
+	changes := make(chan entity.UserChange)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		feed.Follow(context.Background(), changes)
+	}()
+
+	updated := entity.NewUserEvent(entity.UserUpdated, &user, &user)
+	changes <- entity.UserChange{Type: entity.UserUpdated, UserID: user.ID, Version: 2}
+	changes <- entity.UserChange{Type: entity.UserEventPublished, UserID: user.ID, Event: &updated}
+	changes <- entity.UserChange{Type: entity.UserEventPublished, UserID: user.ID, Event: &updated}
+	replay, events, cancel := feed.Subscribe(delivered.ID)
+	defer cancel()
+	assert.Equal(t, []entity.OutboxEvent{updated}, replay,
+		"should start from the outbox and publish the events announced, once",
+	)
+
+	// Events published while the feed was not listening are only found in
+	// the outbox.
+	missed := entity.NewUserEvent(entity.UserDeleted, &user, nil)
+	outbox.events = append(outbox.events, updated, missed)
+	outbox.delivered[updated.ID], outbox.delivered[missed.ID] = true, true
+	changes <- entity.UserChange{Type: entity.UserChangesMissed}
+	changes <- entity.UserChange{Type: entity.UserCreated, UserID: user.ID, Version: 1}
+	_, open := <-events
+	assert.False(t, open, "should end subscriptions once changes were missed")
+
+	replay, _, cancel = feed.Subscribe(updated.ID)
+	defer cancel()
+	assert.Equal(t, []entity.OutboxEvent{missed}, replay, "should resync with the outbox")
+
+	close(changes)
+	<-done
+}
@@ -0,0 +1,156 @@
+// Clean Architecture - Use Case Layer
+// Bulk import of users from CSV
+package usecase
+
+import (
+	"clean-go-rest-api/internal/domain/domainerr"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Import streams the CSV through validation into the repository, one row at
+// a time, so files of any size are imported in constant memory apart from
+// the rejections reported back.
+func (u *UserUseCase) Import(ctx context.Context, req dto.ImportUsersRequest) (dto.ImportUsersReport, error) {
+	reader := csv.NewReader(req.CSV)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return dto.ImportUsersReport{}, domainerr.Validation("the CSV file is empty")
+	}
+	if err != nil {
+		return dto.ImportUsersReport{}, csvError(err)
+	}
+	nameColumn, emailColumn, err := importColumns(header)
+	if err != nil {
+		return dto.ImportUsersReport{}, err
+	}
+
+	report := dto.ImportUsersReport{DryRun: req.DryRun, Rejected: []dto.ImportRejection{}}
+	source := func() (entity.UserImportRow, error) {
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return entity.UserImportRow{}, io.EOF
+			}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount) {
+				report.Rejected = append(report.Rejected, dto.ImportRejection{
+					Line: parseErr.StartLine, Reason: "wrong number of fields",
+				})
+				continue
+			}
+			if err != nil {
+				return entity.UserImportRow{}, csvError(err)
+			}
+
+			line, _ := reader.FieldPos(0)
+			user, err := entity.NewUser(uuid.New(), record[nameColumn], record[emailColumn])
+			if err != nil {
+				report.Rejected = append(report.Rejected, importRejection(line, record[emailColumn], err))
+				continue
+			}
+			return entity.UserImportRow{Line: line, User: user}, nil
+		}
+	}
+
+	var result entity.UserImportResult
+	started := false
+	err = u.uow.Do(ctx, entity.TxOptions{}, func(ctx context.Context) error {
+		// The rows are streamed from the file as they are imported, so an
+		// import aborted by the database cannot be run again.
+		if started {
+			return domainerr.Conflict("the import was aborted by a concurrent change, try again")
+		}
+		started = true
+
+		var err error
+		result, err = u.repo.Import(ctx, source, req.DryRun)
+		if err != nil || req.DryRun {
+			return err
+		}
+		events := make([]entity.OutboxEvent, 0, len(result.Users))
+		for i := range result.Users {
+			events = append(events, entity.NewUserEvent(entity.UserCreated, nil, &result.Users[i]))
+		}
+		return u.outbox.Add(ctx, events...)
+	})
+	if err != nil {
+		return dto.ImportUsersReport{}, err
+	}
+
+	report.Created = int64(len(result.Users))
+	for _, duplicate := range result.Duplicates {
+		reason := "email repeated from an earlier line"
+		if duplicate.Taken {
+			reason = "email already in use"
+		}
+		report.Rejected = append(report.Rejected, dto.ImportRejection{
+			Line: duplicate.Line, Email: duplicate.Email, Reason: reason,
+		})
+	}
+	sort.SliceStable(report.Rejected, func(i, j int) bool {
+		return report.Rejected[i].Line < report.Rejected[j].Line
+	})
+	return report, nil
+}
+
+// importColumns finds the name and email columns, in any order and case.
+func importColumns(header []string) (int, int, error) {
+	nameColumn, emailColumn := -1, -1
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "name":
+			nameColumn = i
+		case "email":
+			emailColumn = i
+		}
+	}
+
+	var fields []domainerr.FieldError
+	if nameColumn < 0 {
+		fields = append(fields, domainerr.FieldError{Field: "name", Message: "column is missing"})
+	}
+	if emailColumn < 0 {
+		fields = append(fields, domainerr.FieldError{Field: "email", Message: "column is missing"})
+	}
+	if len(fields) > 0 {
+		return 0, 0, domainerr.Validation("invalid CSV header", fields...)
+	}
+	return nameColumn, emailColumn, nil
+}
+
+// csvError reports malformed CSV as a validation error. Read errors of the
+// underlying reader are returned as they are.
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return domainerr.Validation(fmt.Sprintf("malformed CSV: %s", parseErr))
+	}
+	return err
+}
+
+func importRejection(line int, email string, err error) dto.ImportRejection {
+	rejection := dto.ImportRejection{Line: line, Email: email, Reason: err.Error()}
+	var domainErr *domainerr.Error
+	if errors.As(err, &domainErr) {
+		rejection.Reason = domainErr.Message
+		for _, field := range domainErr.Fields {
+			rejection.Errors = append(rejection.Errors, dto.FieldError{
+				Field: field.Field, Message: field.Message,
+			})
+		}
+	}
+	return rejection
+}
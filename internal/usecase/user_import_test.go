@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"clean-go-rest-api/internal/domain/domainerr"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type importUsersTestCase struct {
+	testName string
+	csv      string
+	dryRun   bool
+}
+
+func TestUserUseCase_Import(t *testing.T) {
+	const file = "email,name\n" +
+		"mary@example.com,Mary Ann\n" +
+		"not-an-email,\n" +
+		"taken@example.com,Taken User\n" +
+		"MARY@example.com,Mary Twin\n" +
+		"bob@example.com\n" +
+		"bob@example.com,Bob Lee\n"
+
+	tests_scenarios := []importUsersTestCase{
+		{testName: "Import", csv: file},
+		{testName: "Dry Run", csv: file, dryRun: true},
+		{testName: "Missing Column", csv: "name\nJohn Doe\n"},
+		{testName: "Malformed CSV", csv: "name,email\n\"John,john@example.com\n"},
+		{testName: "Empty File", csv: ""},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			repo := SetupMockRepo()
+			taken := entity.User{ID: uuid.New(), Name: "Taken", Email: "taken@example.com", Version: 1}
+			repo.users[taken.ID.String()] = taken
+
+			outbox := SetupMockOutboxRepo()
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), outbox)
+			report, err := useCase.Import(context.Background(), dto.ImportUsersRequest{
+				CSV: strings.NewReader(tt.csv), DryRun: tt.dryRun,
+			})
+
+			switch tt.testName {
+			case tests_scenarios[0].testName, tests_scenarios[1].testName:
+				assert.NoError(t, err, "should not fail on rejected rows")
+				assert.Equal(t, tt.dryRun, report.DryRun)
+				assert.Equal(t, int64(2), report.Created, "should create Mary and Bob")
+				var lines []int
+				var reasons []string
+				for _, rejection := range report.Rejected {
+					lines = append(lines, rejection.Line)
+					reasons = append(reasons, rejection.Reason)
+				}
+				assert.Equal(t, []int{3, 4, 5, 6}, lines, "should report rejections by line")
+				assert.Equal(t, []string{
+					"invalid user",
+					"email already in use",
+					"email repeated from an earlier line",
+					"wrong number of fields",
+				}, reasons)
+				assert.Len(t, report.Rejected[0].Errors, 2, "should detail invalid fields")
+				if tt.dryRun {
+					assert.Len(t, repo.users, 1, "should not write anything on a dry run")
+					assert.Empty(t, outbox.events, "should not record events on a dry run")
+				} else {
+					assert.Len(t, repo.users, 3, "should store the imported users")
+					assert.Len(t, outbox.events, 2, "should record the creation of each imported user")
+					for _, event := range outbox.events {
+						assert.Equal(t, entity.UserCreated, event.Type)
+						assert.Contains(t, repo.users, event.AggregateID.String())
+					}
+				}
+			default:
+				assert.ErrorIs(t, err, domainerr.ErrValidation, "should reject the whole file")
+				assert.Len(t, repo.users, 1)
+				assert.Empty(t, outbox.events)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Clean Architecture - Use Case Layer
+// Background purge of soft deleted users
+package usecase
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"time"
+)
+
+// UserPurger permanently removes users that have been deleted for longer
+// than the retention period.
+type UserPurger struct {
+	repo      entity.IUserRepository
+	logger    logger.ILogger
+	retention time.Duration
+	interval  time.Duration
+	now       func() time.Time
+}
+
+func NewUserPurger(
+	repo entity.IUserRepository, logger logger.ILogger, retention, interval time.Duration,
+) *UserPurger {
+	return &UserPurger{
+		repo:      repo,
+		logger:    logger,
+		retention: retention,
+		interval:  interval,
+		now:       time.Now,
+	}
+}
+
+// PurgeOnce removes every user deleted before the retention cut-off, batch
+// by batch, and reports how many were removed.
+func (p *UserPurger) PurgeOnce(ctx context.Context) (int64, error) {
+	cutoff := p.now().Add(-p.retention)
+	return purgeInBatches(ctx, func(ctx context.Context, limit int) (int64, error) {
+		return p.repo.PurgeDeleted(ctx, cutoff, limit)
+	})
+}
+
+// Run purges on every interval until ctx is done.
+func (p *UserPurger) Run(ctx context.Context) {
+	runPurges(ctx, p.logger, p.interval, "deleted users", p.PurgeOnce)
+}
@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type purgeTestCase struct {
+	testName  string
+	repoSetup func(*UserRepositoryMock, time.Time)
+	expected  int64
+}
+
+func TestUserPurger_PurgeOnce(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	retention := 30 * 24 * time.Hour
+
+	addUser := func(repo *UserRepositoryMock, deletedAt time.Time) uuid.UUID {
+		userID := uuid.New()
+		repo.users[userID.String()] = entity.User{
+			ID:        userID,
+			Name:      "John Doe",
+			Email:     fmt.Sprintf("%s@example.com", userID),
+			DeletedAt: deletedAt,
+		}
+		return userID
+	}
+
+	tests_scenarios := []purgeTestCase{
+		{
+			testName: "Only Expired Users Are Purged",
+			repoSetup: func(repo *UserRepositoryMock, now time.Time) {
+				addUser(repo, time.Time{})
+				addUser(repo, now.Add(-time.Hour))
+				addUser(repo, now.Add(-retention-time.Hour))
+			},
+			expected: 1,
+		},
+		{
+			testName: "Several Batches",
+			repoSetup: func(repo *UserRepositoryMock, now time.Time) {
+				for i := 0; i < purgeBatchSize*2+1; i++ {
+					addUser(repo, now.Add(-retention-time.Minute))
+				}
+			},
+			expected: purgeBatchSize*2 + 1,
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			repo := SetupMockRepo()
+			tt.repoSetup(repo, now)
+			before := len(repo.users)
+
+			purger := NewUserPurger(repo, logger.NewLogger(), retention, time.Hour)
+			purger.now = func() time.Time { return now }
+			purged, err := purger.PurgeOnce(context.Background())
+
+			assert.NoError(t, err, "should not return an error")
+			assert.Equal(t, tt.expected, purged, "should report the purged users")
+			assert.Len(t, repo.users, before-int(tt.expected), "should remove the purged users")
+			for _, user := range repo.users {
+				assert.False(t, user.IsDeleted() && user.DeletedAt.Before(now.Add(-retention)),
+					"should not keep expired users",
+				)
+			}
+		})
+	}
+}
+
+func TestUserPurger_RunStopsWithContext(t *testing.T) {
+	repo := SetupMockRepo()
+	purger := NewUserPurger(repo, logger.NewLogger(), time.Hour, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		purger.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		assert.True(t, errors.Is(ctx.Err(), context.DeadlineExceeded))
+	case <-time.After(time.Second):
+		t.Fatal("purger did not stop after its context was done")
+	}
+}
@@ -1,11 +1,15 @@
 package usecase
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
+	"clean-go-rest-api/internal/domain/domainerr"
 	"clean-go-rest-api/internal/domain/dto"
 	"clean-go-rest-api/internal/domain/entity"
 
@@ -58,6 +62,18 @@ func TestUserUseCase_Add(t *testing.T) {
 			},
 			expected: uuid.Nil,
 		},
+		{
+			testName: "Invalid User",
+			repoSetup: func(repo *UserRepositoryMock) {
+				repo.emailExist = false
+				repo.addErr = nil
+			},
+			input: dto.CreateUserRequest{
+				Name:  "",
+				Email: "not-an-email",
+			},
+			expected: uuid.Nil,
+		},
 	}
 
 	for _, tt := range tests_scenarios {
@@ -65,8 +81,8 @@ func TestUserUseCase_Add(t *testing.T) {
 			repo := SetupMockRepo()
 			tt.repoSetup(repo)
 
-			useCase := NewUserUseCase(repo)
-			result, err := useCase.Add(tt.input)
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+			result, err := useCase.Add(context.Background(), tt.input)
 
 			switch tt.testName {
 			case tests_scenarios[0].testName:
@@ -78,6 +94,7 @@ func TestUserUseCase_Add(t *testing.T) {
 				)
 			case tests_scenarios[1].testName:
 				assert.Error(t, err, "should return an error for existing user")
+				assert.ErrorIs(t, err, domainerr.ErrConflict, "should return a conflict error")
 				assert.Equal(t, uuid.Nil, result, "should return uuid.Nil for existing user")
 			case tests_scenarios[2].testName:
 				assert.Error(t, err, "should return an error when adding user fails")
@@ -86,6 +103,115 @@ func TestUserUseCase_Add(t *testing.T) {
 				assert.EqualError(t,
 					err, "database error", "should return the correct error message",
 				)
+			case tests_scenarios[3].testName:
+				assert.ErrorIs(t, err, domainerr.ErrValidation, "should return a validation error")
+				assert.Equal(t, tt.expected, result, "should return uuid.Nil for an invalid user")
+				assert.Empty(t, repo.users, "should not store an invalid user")
+			}
+		})
+	}
+}
+
+func TestUserUseCase_AddSameEmailTwice(t *testing.T) {
+	repo := SetupMockRepo()
+	useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+
+	_, err := useCase.Add(context.Background(), dto.CreateUserRequest{Name: "John Doe", Email: "john@example.com"})
+	assert.NoError(t, err, "the first signup should succeed")
+
+	_, err = useCase.Add(context.Background(), dto.CreateUserRequest{Name: "John Roe", Email: "JOHN@example.com"})
+	assert.ErrorIs(t, err, domainerr.ErrConflict, "the second signup should be rejected by the repository")
+	assert.Len(t, repo.users, 1, "should only store the first user")
+}
+
+type addBatchTestCase struct {
+	testName  string
+	repoSetup func(*UserRepositoryMock)
+	input     dto.CreateUsersRequest
+}
+
+func TestUserUseCase_AddBatch(t *testing.T) {
+	existing := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com", Version: 1}
+	tests_scenarios := []addBatchTestCase{
+		{
+			testName:  "All Created",
+			repoSetup: func(repo *UserRepositoryMock) {},
+			input: dto.CreateUsersRequest{Users: []dto.CreateUserRequest{
+				{Name: "John Doe", Email: "john@example.com"},
+				{Name: "Mary Ann", Email: "mary@example.com"},
+			}},
+		},
+		{
+			testName:  "Atomic Batch With Invalid User",
+			repoSetup: func(repo *UserRepositoryMock) {},
+			input: dto.CreateUsersRequest{Users: []dto.CreateUserRequest{
+				{Name: "John Doe", Email: "john@example.com"},
+				{Name: "", Email: "not-an-email"},
+			}},
+		},
+		{
+			testName: "Best Effort With Taken Emails",
+			repoSetup: func(repo *UserRepositoryMock) {
+				repo.users[existing.ID.String()] = existing
+			},
+			input: dto.CreateUsersRequest{BestEffort: true, Users: []dto.CreateUserRequest{
+				{Name: "John Again", Email: "John@Example.com"},
+				{Name: "Mary Ann", Email: "mary@example.com"},
+				{Name: "Mary Twin", Email: "mary@example.com"},
+			}},
+		},
+		{
+			testName: "Atomic Batch With Taken Email",
+			repoSetup: func(repo *UserRepositoryMock) {
+				repo.users[existing.ID.String()] = existing
+			},
+			input: dto.CreateUsersRequest{Users: []dto.CreateUserRequest{
+				{Name: "John Again", Email: "john@example.com"},
+				{Name: "Mary Ann", Email: "mary@example.com"},
+			}},
+		},
+		{
+			testName:  "Empty Batch",
+			repoSetup: func(repo *UserRepositoryMock) {},
+			input:     dto.CreateUsersRequest{},
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			repo := SetupMockRepo()
+			tt.repoSetup(repo)
+
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+			results, err := useCase.AddBatch(context.Background(), tt.input)
+
+			switch tt.testName {
+			case tests_scenarios[0].testName:
+				assert.NoError(t, err, "should not return an error for a valid batch")
+				assert.Len(t, repo.users, 2, "should store every user")
+				for _, result := range results {
+					assert.NoError(t, result.Err)
+					assert.Contains(t, repo.users, result.ID.String())
+				}
+			case tests_scenarios[1].testName:
+				assert.NoError(t, err, "should report failures per user")
+				assert.ErrorIs(t, results[0].Err, ErrBatchAborted, "should abort the valid user")
+				assert.ErrorIs(t, results[1].Err, domainerr.ErrValidation)
+				assert.Empty(t, repo.users, "should not store anything")
+			case tests_scenarios[2].testName:
+				assert.NoError(t, err, "should report failures per user")
+				assert.ErrorIs(t, results[0].Err, domainerr.ErrConflict, "should reject a taken email")
+				assert.NoError(t, results[1].Err, "should create the valid user")
+				assert.Contains(t, repo.users, results[1].ID.String())
+				assert.ErrorIs(t, results[2].Err, domainerr.ErrConflict, "should reject a repeated email")
+				assert.Len(t, repo.users, 2)
+			case tests_scenarios[3].testName:
+				assert.NoError(t, err, "should report failures per user")
+				assert.ErrorIs(t, results[0].Err, domainerr.ErrConflict)
+				assert.ErrorIs(t, results[1].Err, ErrBatchAborted)
+				assert.Len(t, repo.users, 1, "should only keep the existing user")
+			case tests_scenarios[4].testName:
+				assert.ErrorIs(t, err, domainerr.ErrValidation, "should reject an empty batch")
 			}
 		})
 	}
@@ -169,14 +295,15 @@ func TestUserUseCase_Delete(t *testing.T) {
 				}
 			}
 
-			useCase := NewUserUseCase(repo)
-			err := useCase.Delete(tt.input)
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+			err := useCase.Delete(context.Background(), tt.input)
 
 			switch tt.testName {
 			case tests_scenarios[0].testName:
 				assert.NoError(t, err, "should not return an error for valid deletion")
 			case tests_scenarios[1].testName:
 				assert.Error(t, err, "should return an error for user not found")
+				assert.ErrorIs(t, err, domainerr.ErrNotFound, "should return a not found error")
 				assert.EqualError(t, err, "user not found", "should return the correct error message")
 			case tests_scenarios[2].testName:
 				assert.Error(t, err, "should return an error when delete fails")
@@ -196,6 +323,56 @@ type updateUserTestCase struct {
 	expected  interface{}
 }
 
+type databaseErrorTestCase struct {
+	testName string
+	err      error
+}
+
+// TestUserUseCase_DatabaseErrors checks that failures of the user store are
+// returned unchanged: the repository already tells an unreachable database
+// apart, and the unit of work retries aborted transactions it sees as is.
+func TestUserUseCase_DatabaseErrors(t *testing.T) {
+	tests_scenarios := []databaseErrorTestCase{
+		{testName: "Unavailable", err: domainerr.Unavailable("the database is unavailable")},
+		{testName: "Transaction Aborted", err: errors.New("could not serialize access")},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName+" On Insert", func(t *testing.T) {
+			repo := SetupMockRepo()
+			repo.addErr = tt.err
+			outbox := SetupMockOutboxRepo()
+
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), outbox)
+			id, err := useCase.Add(context.Background(), dto.CreateUserRequest{
+				Name: "John Doe", Email: "john@example.com",
+			})
+
+			assert.Same(t, tt.err, err, "should return the insert error unchanged")
+			assert.Equal(t, uuid.Nil, id)
+			assert.Empty(t, outbox.events, "should not record an event for a failed insert")
+		})
+		t.Run(tt.testName+" On Email Check", func(t *testing.T) {
+			repo := SetupMockRepo()
+			userID := uuid.New()
+			repo.users[userID.String()] = entity.User{
+				ID: userID, Name: "John Doe", Email: "john@example.com", Version: 1,
+			}
+			repo.emailExistsErr = tt.err
+
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+			_, err := useCase.Update(context.Background(), dto.UpdateUserRequest{
+				ID: userID, Name: "John Doe", Email: "john.new@example.com",
+			})
+
+			assert.Same(t, tt.err, err, "should return the lookup error unchanged")
+			assert.Equal(t, "john@example.com", repo.users[userID.String()].Email,
+				"should not update a user whose email could not be checked",
+			)
+		})
+	}
+}
+
 func TestUserUseCase_Update(t *testing.T) {
 	tests_scenarios := []updateUserTestCase{
 		{
@@ -227,6 +404,55 @@ func TestUserUseCase_Update(t *testing.T) {
 			},
 			expected: errors.New("user not found"),
 		},
+		{
+			testName: "Email Taken",
+			repoSetup: func(repo *UserRepositoryMock) {
+				userID := uuid.New()
+				repo.users[userID.String()] = entity.User{
+					ID:    userID,
+					Name:  "John Doe",
+					Email: "john.doe@xample.com",
+				}
+				repo.emailExist = true
+			},
+		},
+		{
+			testName: "Same Email Kept",
+			repoSetup: func(repo *UserRepositoryMock) {
+				userID := uuid.New()
+				repo.users[userID.String()] = entity.User{
+					ID:    userID,
+					Name:  "John Doe",
+					Email: "john.doe@xample.com",
+				}
+				repo.emailExist = true
+			},
+		},
+		{
+			testName: "Invalid Update",
+			repoSetup: func(repo *UserRepositoryMock) {
+				userID := uuid.New()
+				repo.users[userID.String()] = entity.User{
+					ID:    userID,
+					Name:  "John Doe",
+					Email: "john.doe@xample.com",
+				}
+				repo.getByIdErr = nil
+				repo.updateErr = nil
+			},
+		},
+		{
+			testName: "Email Check Unavailable",
+			repoSetup: func(repo *UserRepositoryMock) {
+				userID := uuid.New()
+				repo.users[userID.String()] = entity.User{
+					ID:    userID,
+					Name:  "John Doe",
+					Email: "john.doe@xample.com",
+				}
+				repo.emailExistsErr = domainerr.Unavailable("unable to check whether the email is in use")
+			},
+		},
 	}
 
 	for _, tt := range tests_scenarios {
@@ -234,7 +460,7 @@ func TestUserUseCase_Update(t *testing.T) {
 			repo := SetupMockRepo()
 			tt.repoSetup(repo)
 
-			if tt.testName == tests_scenarios[0].testName {
+			if tt.testName == tests_scenarios[0].testName || tt.testName == tests_scenarios[6].testName {
 				for id := range repo.users {
 					uuidVal, _ := uuid.Parse(id)
 					tt.input.ID = uuidVal
@@ -242,9 +468,33 @@ func TestUserUseCase_Update(t *testing.T) {
 					tt.input.Email = "john.new@example.com"
 				}
 			}
+			if tt.testName == tests_scenarios[3].testName {
+				for id := range repo.users {
+					uuidVal, _ := uuid.Parse(id)
+					tt.input.ID = uuidVal
+					tt.input.Name = "John new"
+					tt.input.Email = "jane.doe@example.com"
+				}
+			}
+			if tt.testName == tests_scenarios[4].testName {
+				for id := range repo.users {
+					uuidVal, _ := uuid.Parse(id)
+					tt.input.ID = uuidVal
+					tt.input.Name = "John new"
+					tt.input.Email = "John.Doe@xample.com"
+				}
+			}
+			if tt.testName == tests_scenarios[5].testName {
+				for id := range repo.users {
+					uuidVal, _ := uuid.Parse(id)
+					tt.input.ID = uuidVal
+					tt.input.Name = "John new"
+					tt.input.Email = "john.new"
+				}
+			}
 
-			useCase := NewUserUseCase(repo)
-			err := useCase.Update(tt.input)
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+			_, err := useCase.Update(context.Background(), tt.input)
 
 			switch tt.testName {
 			case tests_scenarios[0].testName:
@@ -254,12 +504,327 @@ func TestUserUseCase_Update(t *testing.T) {
 				assert.Equal(t, err, tt.expected)
 			case tests_scenarios[2].testName:
 				assert.Error(t, err, "should return an error for unextisting user")
-				assert.Equal(t, err, tt.expected)
+				assert.ErrorIs(t, err, domainerr.ErrNotFound, "should return a not found error")
+				assert.EqualError(t, err, tt.expected.(error).Error())
+			case tests_scenarios[3].testName:
+				assert.ErrorIs(t, err, domainerr.ErrConflict, "should return a conflict error")
+				assert.Equal(t, "John Doe", repo.users[tt.input.ID.String()].Name,
+					"should not update a user whose new email is taken",
+				)
+			case tests_scenarios[4].testName:
+				assert.NoError(t, err, "should not check uniqueness when the email is unchanged")
+				assert.Equal(t, "John new", repo.users[tt.input.ID.String()].Name)
+			case tests_scenarios[5].testName:
+				assert.ErrorIs(t, err, domainerr.ErrValidation, "should return a validation error")
+				assert.Equal(t, "john.doe@xample.com", repo.users[tt.input.ID.String()].Email,
+					"should not store an invalid email",
+				)
+			case tests_scenarios[6].testName:
+				assert.ErrorIs(t, err, domainerr.ErrUnavailable, "should report the user store unavailable")
+				assert.Equal(t, "John Doe", repo.users[tt.input.ID.String()].Name,
+					"should not update a user whose email could not be checked",
+				)
 			}
 		})
 	}
 }
 
+type patchUserTestCase struct {
+	testName  string
+	repoSetup func(*UserRepositoryMock)
+	input     dto.PatchUserRequest
+	expected  interface{}
+}
+
+func TestUserUseCase_Patch(t *testing.T) {
+	newName := "  John Patched "
+	takenEmail := "taken@example.com"
+	invalidEmail := "not-an-email"
+
+	tests_scenarios := []patchUserTestCase{
+		{
+			testName: "Patch Name Only",
+			repoSetup: func(repo *UserRepositoryMock) {
+				userID := uuid.New()
+				repo.users[userID.String()] = entity.User{
+					ID:    userID,
+					Name:  "John Doe",
+					Email: "john.doe@example.com",
+				}
+			},
+			input: dto.PatchUserRequest{Name: &newName},
+		},
+		{
+			testName: "User Not Found",
+			repoSetup: func(repo *UserRepositoryMock) {
+			},
+			input:    dto.PatchUserRequest{ID: uuid.New(), Name: &newName},
+			expected: domainerr.ErrNotFound,
+		},
+		{
+			testName: "Email Taken",
+			repoSetup: func(repo *UserRepositoryMock) {
+				userID := uuid.New()
+				repo.users[userID.String()] = entity.User{
+					ID:    userID,
+					Name:  "John Doe",
+					Email: "john.doe@example.com",
+				}
+				repo.emailExist = true
+			},
+			input:    dto.PatchUserRequest{Email: &takenEmail},
+			expected: domainerr.ErrConflict,
+		},
+		{
+			testName: "Invalid Email",
+			repoSetup: func(repo *UserRepositoryMock) {
+				userID := uuid.New()
+				repo.users[userID.String()] = entity.User{
+					ID:    userID,
+					Name:  "John Doe",
+					Email: "john.doe@example.com",
+				}
+			},
+			input:    dto.PatchUserRequest{Email: &invalidEmail},
+			expected: domainerr.ErrValidation,
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			repo := SetupMockRepo()
+			tt.repoSetup(repo)
+
+			for id := range repo.users {
+				tt.input.ID, _ = uuid.Parse(id)
+			}
+
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+			user, err := useCase.Patch(context.Background(), tt.input)
+
+			switch tt.testName {
+			case tests_scenarios[0].testName:
+				assert.NoError(t, err, "should not return an error for a valid patch")
+				assert.Equal(t, "John Patched", user.Name, "should apply the normalized name")
+				assert.Equal(t, "john.doe@example.com", user.Email, "should keep the email")
+				assert.Equal(t, user, repo.users[tt.input.ID.String()], "should store the patched user")
+			default:
+				assert.ErrorIs(t, err, tt.expected.(error))
+				assert.Equal(t, entity.User{}, user, "should return an empty user")
+				for _, stored := range repo.users {
+					assert.Equal(t, "john.doe@example.com", stored.Email, "should not store the patch")
+				}
+			}
+		})
+	}
+}
+
+type noopPatchTestCase struct {
+	testName string
+	input    dto.PatchUserRequest
+	expected error
+}
+
+func TestUserUseCase_PatchWithoutChange(t *testing.T) {
+	sameName := "John Doe"
+
+	tests_scenarios := []noopPatchTestCase{
+		{testName: "Empty Patch", input: dto.PatchUserRequest{}},
+		{testName: "Same Values", input: dto.PatchUserRequest{Name: &sameName, Versions: []int64{2}}},
+		{
+			testName: "Stale Version",
+			input:    dto.PatchUserRequest{Name: &sameName, Versions: []int64{1}},
+			expected: domainerr.ErrPreconditionFailed,
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			repo := SetupMockRepo()
+			outbox := SetupMockOutboxRepo()
+			stored := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john.doe@example.com", Version: 2}
+			repo.users[stored.ID.String()] = stored
+			tt.input.ID = stored.ID
+
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), outbox)
+			user, err := useCase.Patch(context.Background(), tt.input)
+
+			assert.ErrorIs(t, err, tt.expected)
+			if tt.expected == nil {
+				assert.Equal(t, stored, user, "should return the user as stored")
+			}
+			assert.Equal(t, stored, repo.users[stored.ID.String()], "should not write the user")
+			assert.Empty(t, outbox.events, "should not record an event")
+		})
+	}
+}
+
+func TestUserUseCase_OptimisticConcurrency(t *testing.T) {
+	repo := SetupMockRepo()
+	userID := uuid.New()
+	repo.users[userID.String()] = entity.User{
+		ID:      userID,
+		Name:    "John Doe",
+		Email:   "john.doe@example.com",
+		Version: 2,
+	}
+	uow := SetupMockUnitOfWork()
+	useCase := NewUserUseCase(repo, uow, SetupMockOutboxRepo())
+	ctx := context.Background()
+
+	_, err := useCase.Update(ctx, dto.UpdateUserRequest{
+		ID: userID, Name: "John new", Email: "john.doe@example.com", Versions: []int64{1},
+	})
+	assert.ErrorIs(t, err, domainerr.ErrPreconditionFailed, "should reject a stale update")
+
+	newName := "John patched"
+	_, err = useCase.Patch(ctx, dto.PatchUserRequest{ID: userID, Name: &newName, Versions: []int64{1}})
+	assert.ErrorIs(t, err, domainerr.ErrPreconditionFailed, "should reject a stale patch")
+
+	err = useCase.Delete(ctx, dto.DeleteUserRequest{ID: userID, Versions: []int64{1}})
+	assert.ErrorIs(t, err, domainerr.ErrPreconditionFailed, "should reject a stale delete")
+	assert.Equal(t, "John Doe", repo.users[userID.String()].Name, "should keep the stored user")
+
+	user, err := useCase.Update(ctx, dto.UpdateUserRequest{
+		ID: userID, Name: "John new", Email: "john.doe@example.com", Versions: []int64{1, 2},
+	})
+	assert.NoError(t, err, "should accept an update listing the current version")
+	assert.Equal(t, int64(3), user.Version, "should return the new version")
+
+	user, err = useCase.Patch(ctx, dto.PatchUserRequest{ID: userID, Name: &newName})
+	assert.NoError(t, err, "should accept a patch without an expected version")
+	assert.Equal(t, int64(4), user.Version, "should return the new version")
+
+	err = useCase.Delete(ctx, dto.DeleteUserRequest{ID: userID, Versions: []int64{4}})
+	assert.NoError(t, err, "should accept a delete on the current version")
+	assert.True(t, repo.users[userID.String()].IsDeleted(), "should soft delete the user")
+	assert.Equal(t, 6, uow.units, "should read and write each user in a single unit of work")
+}
+
+type restoreUserTestCase struct {
+	testName  string
+	repoSetup func(*UserRepositoryMock)
+	input     dto.RestoreUserRequest
+	expected  error
+}
+
+func TestUserUseCase_Restore(t *testing.T) {
+	deletedUser := func(repo *UserRepositoryMock) {
+		userID := uuid.New()
+		repo.users[userID.String()] = entity.User{
+			ID:        userID,
+			Name:      "John Doe",
+			Email:     "john.doe@example.com",
+			Version:   2,
+			DeletedAt: time.Now().Add(-time.Hour),
+		}
+	}
+	tests_scenarios := []restoreUserTestCase{
+		{
+			testName:  "Valid Restore",
+			repoSetup: deletedUser,
+		},
+		{
+			testName: "User Not Deleted",
+			repoSetup: func(repo *UserRepositoryMock) {
+				userID := uuid.New()
+				repo.users[userID.String()] = entity.User{
+					ID:      userID,
+					Name:    "John Doe",
+					Email:   "john.doe@example.com",
+					Version: 1,
+				}
+			},
+			expected: domainerr.ErrConflict,
+		},
+		{
+			testName: "Email Taken Meanwhile",
+			repoSetup: func(repo *UserRepositoryMock) {
+				deletedUser(repo)
+				repo.emailExist = true
+			},
+			expected: domainerr.ErrConflict,
+		},
+		{
+			testName: "Stale Version",
+			repoSetup: func(repo *UserRepositoryMock) {
+				deletedUser(repo)
+			},
+			input:    dto.RestoreUserRequest{Versions: []int64{1}},
+			expected: domainerr.ErrPreconditionFailed,
+		},
+		{
+			testName:  "User Not Found",
+			repoSetup: func(repo *UserRepositoryMock) {},
+			input:     dto.RestoreUserRequest{ID: uuid.New()},
+			expected:  domainerr.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			repo := SetupMockRepo()
+			tt.repoSetup(repo)
+
+			for id := range repo.users {
+				tt.input.ID, _ = uuid.Parse(id)
+			}
+
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+			user, err := useCase.Restore(context.Background(), tt.input)
+
+			if tt.expected != nil {
+				assert.ErrorIs(t, err, tt.expected)
+				assert.Equal(t, entity.User{}, user, "should return an empty user")
+				return
+			}
+			assert.NoError(t, err, "should restore a deleted user")
+			assert.False(t, user.IsDeleted(), "should clear the deletion time")
+			assert.Equal(t, int64(3), user.Version, "should bump the version")
+
+			found, err := useCase.GetById(context.Background(), tt.input.ID)
+			assert.NoError(t, err, "should find the restored user")
+			assert.Equal(t, user, found)
+		})
+	}
+}
+
+func TestUserUseCase_DeletedUsersAreHidden(t *testing.T) {
+	repo := SetupMockRepo()
+	userID := uuid.New()
+	repo.users[userID.String()] = entity.User{
+		ID:      userID,
+		Name:    "John Doe",
+		Email:   "john.doe@example.com",
+		Version: 1,
+	}
+	useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+	ctx := context.Background()
+
+	err := useCase.Delete(ctx, dto.DeleteUserRequest{ID: userID})
+	assert.NoError(t, err, "should delete the user")
+
+	_, err = useCase.GetById(ctx, userID)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound, "should hide the deleted user")
+
+	user, err := useCase.GetByIdIncludingDeleted(ctx, userID)
+	assert.NoError(t, err, "should find the deleted user on request")
+	assert.True(t, user.IsDeleted())
+
+	page, err := useCase.Search(ctx, dto.SearchUsersRequest{Name: "John"})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Users, "should leave deleted users out of searches")
+
+	page, err = useCase.Search(ctx, dto.SearchUsersRequest{Name: "John", IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Len(t, page.Users, 1, "should list deleted users on request")
+
+	_, err = useCase.Update(ctx, dto.UpdateUserRequest{
+		ID: userID, Name: "John new", Email: "john.doe@example.com",
+	})
+	assert.ErrorIs(t, err, domainerr.ErrNotFound, "should not update a deleted user")
+}
+
 type getByIdUserTestCase struct {
 	testName  string
 	repoSetup func(*UserRepositoryMock)
@@ -287,6 +852,12 @@ func TestUserUseCase_GetById(t *testing.T) {
 				repo.getByIdErr = errors.New("user not found")
 			},
 		},
+		{
+			testName: "User missing",
+			repoSetup: func(repo *UserRepositoryMock) {
+				repo.getByIdErr = nil
+			},
+		},
 	}
 
 	for _, tt := range tests_scenarios {
@@ -301,8 +872,8 @@ func TestUserUseCase_GetById(t *testing.T) {
 				}
 			}
 
-			useCase := NewUserUseCase(repo)
-			user, err := useCase.GetById(tt.input.ID)
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+			user, err := useCase.GetById(context.Background(), tt.input.ID)
 
 			switch tt.testName {
 			case tests_scenarios[0].testName:
@@ -314,6 +885,9 @@ func TestUserUseCase_GetById(t *testing.T) {
 				assert.Error(t, err, "should return an error for unexisting user")
 				assert.Equal(t, err, tt.expected)
 				assert.Equal(t, user, entity.User{})
+			case tests_scenarios[2].testName:
+				assert.ErrorIs(t, err, domainerr.ErrNotFound, "should return a not found error")
+				assert.Equal(t, user, entity.User{})
 			}
 		})
 	}
@@ -322,7 +896,7 @@ func TestUserUseCase_GetById(t *testing.T) {
 type searchUserTestCase struct {
 	testName  string
 	repoSetup func(*UserRepositoryMock)
-	input     string
+	input     dto.SearchUsersRequest
 	expected  []entity.User
 }
 
@@ -338,13 +912,90 @@ func TestUserUseCase_Search(t *testing.T) {
 					Email: "john.doe@example.com",
 				}
 			},
-			input: "John",
+			input: dto.SearchUsersRequest{Name: "John"},
 		},
 		{
 			testName: "User Not Found",
 			repoSetup: func(repo *UserRepositoryMock) {
 			},
-			input: "John",
+			input: dto.SearchUsersRequest{Name: "John"},
+		},
+		{
+			testName: "Filtered And Sorted By Creation",
+			repoSetup: func(repo *UserRepositoryMock) {
+				base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+				for i, name := range []string{"John Old", "John Mid", "John New"} {
+					userId := uuid.New()
+					repo.users[userId.String()] = entity.User{
+						ID:        userId,
+						Name:      name,
+						Email:     fmt.Sprintf("john%d@example.com", i),
+						CreatedAt: base.AddDate(0, i, 0),
+					}
+				}
+			},
+			input: dto.SearchUsersRequest{
+				Name:         "John",
+				CreatedAfter: "2024-01-15T00:00:00Z",
+				Sort:         "-created_at",
+			},
+		},
+		{
+			testName: "Invalid Criteria",
+			repoSetup: func(repo *UserRepositoryMock) {
+			},
+			input: dto.SearchUsersRequest{
+				CreatedAfter:  "2024-02-01T00:00:00Z",
+				CreatedBefore: "2024-01-01T00:00:00Z",
+				UpdatedBefore: "yesterday",
+				Sort:          "password",
+				Limit:         "500",
+				Cursor:        "not-a-cursor",
+			},
+		},
+		{
+			testName: "Listed By Email Domain Sorted By Name",
+			repoSetup: func(repo *UserRepositoryMock) {
+				for _, user := range []entity.User{
+					{ID: uuid.New(), Name: "Mary", Email: "mary@example.com"},
+					{ID: uuid.New(), Name: "Ann", Email: "ann@example.com"},
+					{ID: uuid.New(), Name: "Bob", Email: "bob@other.com"},
+				} {
+					repo.users[user.ID.String()] = user
+				}
+			},
+			input: dto.SearchUsersRequest{EmailDomain: "@Example.com", Sort: "name"},
+		},
+		{
+			testName: "Exact Email",
+			repoSetup: func(repo *UserRepositoryMock) {
+				for _, user := range []entity.User{
+					{ID: uuid.New(), Name: "Mary", Email: "mary@example.com"},
+					{ID: uuid.New(), Name: "Mary Ann", Email: "mary.ann@example.com"},
+				} {
+					repo.users[user.ID.String()] = user
+				}
+			},
+			input: dto.SearchUsersRequest{Email: " Mary@Example.com "},
+		},
+		{
+			testName: "Fuzzy Search Ranked",
+			repoSetup: func(repo *UserRepositoryMock) {
+				for _, user := range []entity.User{
+					{ID: uuid.New(), Name: "Jane Doe", Email: "jane@example.com"},
+					{ID: uuid.New(), Name: "John Doe", Email: "jd@example.com"},
+					{ID: uuid.New(), Name: "Mary Ann", Email: "mary@example.com"},
+				} {
+					repo.users[user.ID.String()] = user
+				}
+			},
+			input: dto.SearchUsersRequest{Mode: "fuzzy", Query: "jon doe"},
+		},
+		{
+			testName: "Fuzzy Search Without Query",
+			repoSetup: func(repo *UserRepositoryMock) {
+			},
+			input: dto.SearchUsersRequest{Mode: "fuzzy", Sort: "relevance"},
 		},
 	}
 
@@ -359,8 +1010,9 @@ func TestUserUseCase_Search(t *testing.T) {
 				}
 			}
 
-			useCase := NewUserUseCase(repo)
-			users, err := useCase.Search(tt.input)
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+			page, err := useCase.Search(context.Background(), tt.input)
+			users := page.Users
 
 			switch tt.testName {
 			case tests_scenarios[0].testName:
@@ -369,7 +1021,268 @@ func TestUserUseCase_Search(t *testing.T) {
 			case tests_scenarios[1].testName:
 				assert.NoError(t, err, "should not return an error to search")
 				assert.Equal(t, users, tt.expected)
+			case tests_scenarios[2].testName:
+				assert.NoError(t, err, "should not return an error to search")
+				var names []string
+				for _, user := range users {
+					names = append(names, user.Name)
+				}
+				assert.Equal(t, []string{"John New", "John Mid"}, names,
+					"should only keep users created after the bound, newest first",
+				)
+			case tests_scenarios[3].testName:
+				assert.ErrorIs(t, err, domainerr.ErrValidation, "should reject invalid criteria")
+				var domainErr *domainerr.Error
+				assert.True(t, errors.As(err, &domainErr))
+				var fields []string
+				for _, field := range domainErr.Fields {
+					fields = append(fields, field.Field)
+				}
+				assert.Equal(t, []string{"updated_before", "created_after", "sort", "limit", "cursor"}, fields)
+			case tests_scenarios[4].testName:
+				assert.NoError(t, err, "should list users without a name")
+				var names []string
+				for _, user := range users {
+					names = append(names, user.Name)
+				}
+				assert.Equal(t, []string{"Ann", "Mary"}, names,
+					"should only keep users of the domain, sorted by name",
+				)
+			case tests_scenarios[5].testName:
+				assert.NoError(t, err, "should search by email")
+				assert.Len(t, users, 1, "should match the whole address only")
+				assert.Equal(t, "mary@example.com", users[0].Email)
+			case tests_scenarios[6].testName:
+				assert.NoError(t, err, "should search by similarity")
+				var names []string
+				for _, user := range users {
+					names = append(names, user.Name)
+				}
+				assert.Equal(t, []string{"John Doe", "Jane Doe"}, names,
+					"should leave dissimilar users out and rank the closest match first",
+				)
+				assert.Greater(t, page.Scores[users[0].ID], page.Scores[users[1].ID],
+					"should score every user of the page",
+				)
+			case tests_scenarios[7].testName:
+				assert.ErrorIs(t, err, domainerr.ErrValidation, "should require a query in fuzzy mode")
+			}
+		})
+	}
+}
+
+func TestUserUseCase_SearchPagination(t *testing.T) {
+	repo := SetupMockRepo()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		userId := uuid.New()
+		repo.users[userId.String()] = entity.User{
+			ID:        userId,
+			Name:      fmt.Sprintf("John %d", i),
+			Email:     fmt.Sprintf("john%d@example.com", i),
+			CreatedAt: base.AddDate(0, 0, i),
+		}
+	}
+	useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+
+	var names []string
+	req := dto.SearchUsersRequest{Name: "John", Sort: "-created_at", Limit: "2"}
+	for pages := 0; pages < 5; pages++ {
+		page, err := useCase.Search(context.Background(), req)
+		assert.NoError(t, err, "should not return an error for a page")
+		assert.LessOrEqual(t, len(page.Users), 2, "should never exceed the limit")
+		for _, user := range page.Users {
+			names = append(names, user.Name)
+		}
+		if page.Next == nil {
+			break
+		}
+		req.Cursor = page.Next.Encode()
+	}
+	assert.Equal(t, []string{"John 4", "John 3", "John 2", "John 1", "John 0"}, names,
+		"should walk every user exactly once, in order",
+	)
+
+	first, err := useCase.Search(context.Background(), dto.SearchUsersRequest{Limit: "2"})
+	assert.NoError(t, err)
+	_, err = useCase.Search(context.Background(), dto.SearchUsersRequest{
+		Sort: "-created_at", Cursor: first.Next.Encode(),
+	})
+	assert.ErrorIs(t, err, domainerr.ErrValidation, "should reject a cursor issued for another order")
+
+	page, err := useCase.Search(context.Background(), dto.SearchUsersRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Users, 5, "should use the default page size")
+	assert.Nil(t, page.Next, "should not point past the last page")
+}
+
+func TestUserUseCase_Export(t *testing.T) {
+	repo := SetupMockRepo()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 150; i++ {
+		userId := uuid.New()
+		repo.users[userId.String()] = entity.User{
+			ID:        userId,
+			Name:      fmt.Sprintf("User %d", i),
+			Email:     fmt.Sprintf("user%d@example.com", i),
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+
+	var exported []entity.User
+	err := useCase.Export(context.Background(), dto.SearchUsersRequest{Sort: "-created_at"},
+		func(user entity.User) error {
+			exported = append(exported, user)
+			return nil
+		},
+	)
+	assert.NoError(t, err, "should export without pagination")
+	assert.Len(t, exported, 150, "should not stop at a page")
+	assert.Equal(t, "User 149", exported[0].Name, "should follow the requested order")
+
+	stop := errors.New("client went away")
+	calls := 0
+	err = useCase.Export(context.Background(), dto.SearchUsersRequest{}, func(entity.User) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop, "should stop at the first error")
+	assert.Equal(t, 1, calls)
+
+	err = useCase.Export(context.Background(), dto.SearchUsersRequest{Limit: "10"}, func(entity.User) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, domainerr.ErrValidation, "should reject pagination parameters")
+}
+
+type suggestUserTestCase struct {
+	testName string
+	input    dto.SuggestUsersRequest
+	expected []string
+}
+
+func TestUserUseCase_Suggest(t *testing.T) {
+	tests_scenarios := []suggestUserTestCase{
+		{
+			testName: "Name Prefix",
+			input:    dto.SuggestUsersRequest{Prefix: " JO"},
+			expected: []string{"John Doe", "Joseph Lee", "Mary Ann"},
+		},
+		{
+			testName: "Limited",
+			input:    dto.SuggestUsersRequest{Prefix: "jo", Limit: "1"},
+			expected: []string{"John Doe"},
+		},
+		{
+			testName: "Invalid Request",
+			input:    dto.SuggestUsersRequest{Limit: "26"},
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			repo := SetupMockRepo()
+			for _, user := range []entity.User{
+				{ID: uuid.New(), Name: "Joseph Lee", Email: "joseph@example.com"},
+				{ID: uuid.New(), Name: "Mary Ann", Email: "jo.mary@example.com"},
+				{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"},
+				{ID: uuid.New(), Name: "Bob Jones", Email: "bob@example.com"},
+			} {
+				repo.users[user.ID.String()] = user
+			}
+
+			useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+			suggestions, err := useCase.Suggest(context.Background(), tt.input)
+
+			switch tt.testName {
+			case tests_scenarios[2].testName:
+				assert.ErrorIs(t, err, domainerr.ErrValidation, "should reject an invalid request")
+				var domainErr *domainerr.Error
+				assert.True(t, errors.As(err, &domainErr))
+				assert.Len(t, domainErr.Fields, 2, "should report the prefix and the limit")
+			default:
+				assert.NoError(t, err, "should not return an error for suggestions")
+				var names []string
+				for _, suggestion := range suggestions {
+					names = append(names, suggestion.Name)
+				}
+				assert.Equal(t, tt.expected, names)
 			}
 		})
 	}
 }
+
+func TestUserUseCase_CanceledContext(t *testing.T) {
+	repo := SetupMockRepo()
+	userID := uuid.New()
+	repo.users[userID.String()] = entity.User{
+		ID:    userID,
+		Name:  "John Doe",
+		Email: "john.doe@example.com",
+	}
+	useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), SetupMockOutboxRepo())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := useCase.Add(ctx, dto.CreateUserRequest{Name: "Jane Doe", Email: "jane@example.com"})
+	assert.ErrorIs(t, err, context.Canceled, "add should be canceled")
+
+	_, err = useCase.GetById(ctx, userID)
+	assert.ErrorIs(t, err, context.Canceled, "get should be canceled")
+
+	_, err = useCase.Update(ctx, dto.UpdateUserRequest{ID: userID, Name: "John new", Email: "john.new@example.com"})
+	assert.ErrorIs(t, err, context.Canceled, "update should be canceled")
+
+	err = useCase.Delete(ctx, dto.DeleteUserRequest{ID: userID})
+	assert.ErrorIs(t, err, context.Canceled, "delete should be canceled")
+
+	_, err = useCase.Search(ctx, dto.SearchUsersRequest{Name: "John"})
+	assert.ErrorIs(t, err, context.Canceled, "search should be canceled")
+
+	assert.Len(t, repo.users, 1, "should not modify the repository")
+	assert.Equal(t, "John Doe", repo.users[userID.String()].Name)
+}
+
+func TestUserUseCase_Events(t *testing.T) {
+	repo := SetupMockRepo()
+	outbox := SetupMockOutboxRepo()
+	useCase := NewUserUseCase(repo, SetupMockUnitOfWork(), outbox)
+	ctx := context.Background()
+
+	payload := func(event entity.OutboxEvent) entity.UserEventPayload {
+		var payload entity.UserEventPayload
+		assert.NoError(t, json.Unmarshal(event.Payload, &payload), "should store a JSON payload")
+		return payload
+	}
+
+	id, err := useCase.Add(ctx, dto.CreateUserRequest{Name: "John Doe", Email: "john@example.com"})
+	assert.NoError(t, err)
+	_, err = useCase.Update(ctx, dto.UpdateUserRequest{ID: id, Name: "John Roe", Email: "john@example.com"})
+	assert.NoError(t, err)
+	err = useCase.Delete(ctx, dto.DeleteUserRequest{ID: id})
+	assert.NoError(t, err)
+
+	_, err = useCase.AddBatch(ctx, dto.CreateUsersRequest{Users: []dto.CreateUserRequest{
+		{Name: "Mary Ann", Email: "mary@example.com"},
+		{Name: "Jane Doe", Email: "not-an-email"},
+	}})
+	assert.NoError(t, err)
+
+	if assert.Len(t, outbox.events, 3, "should record one event per change, none for aborted batches") {
+		created, updated, deleted := outbox.events[0], outbox.events[1], outbox.events[2]
+		assert.Equal(t, entity.UserCreated, created.Type)
+		assert.Equal(t, id, created.AggregateID)
+		assert.Nil(t, payload(created).Before, "a creation has no previous state")
+		assert.Equal(t, "John Doe", payload(created).After.Name)
+
+		assert.Equal(t, entity.UserUpdated, updated.Type)
+		assert.Equal(t, "John Doe", payload(updated).Before.Name)
+		assert.Equal(t, "John Roe", payload(updated).After.Name)
+
+		assert.Equal(t, entity.UserDeleted, deleted.Type)
+		assert.Equal(t, "John Roe", payload(deleted).Before.Name)
+		assert.Nil(t, payload(deleted).After, "a deletion has no next state")
+	}
+}
@@ -0,0 +1,108 @@
+// Clean Architecture - Use Case Layer
+// Webhook subscriptions and the fan-out of events to them
+package usecase
+
+import (
+	"clean-go-rest-api/internal/domain/domainerr"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxWebhookDeliveries caps how many deliveries are listed per subscription.
+const MaxWebhookDeliveries = 100
+
+type IWebhookUseCase interface {
+	Subscribe(ctx context.Context, req dto.CreateWebhookRequest) (entity.WebhookSubscription, error)
+	Deliveries(ctx context.Context, subscriptionID uuid.UUID) ([]entity.WebhookDelivery, error)
+}
+
+type WebhookUseCase struct {
+	repo entity.IWebhookRepository
+}
+
+func NewWebhookUseCase(repo entity.IWebhookRepository) IWebhookUseCase {
+	return &WebhookUseCase{repo: repo}
+}
+
+func (u *WebhookUseCase) Subscribe(
+	ctx context.Context, req dto.CreateWebhookRequest,
+) (entity.WebhookSubscription, error) {
+	subscription, err := entity.NewWebhookSubscription(uuid.New(), req.URL, req.EventTypes, req.Secret)
+	if err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	return u.repo.AddSubscription(ctx, subscription)
+}
+
+// Deliveries lists the latest MaxWebhookDeliveries deliveries to a
+// subscription, newest first.
+func (u *WebhookUseCase) Deliveries(ctx context.Context, subscriptionID uuid.UUID) ([]entity.WebhookDelivery, error) {
+	subscription, err := u.repo.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.ID == uuid.Nil {
+		return nil, domainerr.NotFound("webhook subscription not found")
+	}
+	return u.repo.Deliveries(ctx, subscriptionID, MaxWebhookDeliveries)
+}
+
+// webhookEnvelope is the body posted to subscribers. ID is the ID of the
+// event, the same across retries and subscriptions, for receivers to drop
+// duplicates with.
+type webhookEnvelope struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// WebhookPublisher publishes outbox events by queueing a delivery to every
+// subscription to them. The deliveries are stored in the unit of work of
+// the dispatcher, so they are queued exactly once per event even if the
+// event is published again.
+type WebhookPublisher struct {
+	repo entity.IWebhookRepository
+	now  func() time.Time
+}
+
+func NewWebhookPublisher(repo entity.IWebhookRepository) *WebhookPublisher {
+	return &WebhookPublisher{repo: repo, now: time.Now}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	subscriptions, err := p.repo.SubscriptionsFor(ctx, event.Type)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	body, err := json.Marshal(webhookEnvelope{
+		ID:         event.ID,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt.UTC(),
+		Data:       json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	now := p.now()
+	deliveries := make([]entity.WebhookDelivery, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		deliveries = append(deliveries, entity.WebhookDelivery{
+			ID:             uuid.New(),
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Body:           body,
+			Status:         entity.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+		})
+	}
+	return p.repo.AddDeliveries(ctx, deliveries...)
+}
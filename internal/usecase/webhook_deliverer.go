@@ -0,0 +1,156 @@
+// Clean Architecture - Use Case Layer
+// Background delivery of webhooks, with retries
+package usecase
+
+import (
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/entity"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// deliverBatchSize bounds how many webhooks a deliverer sends at once.
+	deliverBatchSize = 20
+
+	// A failed delivery is retried after webhookRetryBase, doubling on
+	// every further failure up to webhookRetryMax.
+	webhookRetryBase = 10 * time.Second
+	webhookRetryMax  = time.Hour
+)
+
+// WebhookDeliverer sends the webhooks that are due, and schedules failed ones
+// for another attempt until maxAttempts were made, after which they are
+// dead-lettered. Webhooks are delivered at least once: a crash before an
+// attempt is recorded means it is made again.
+type WebhookDeliverer struct {
+	repo        entity.IWebhookRepository
+	sender      entity.IWebhookSender
+	logger      logger.ILogger
+	interval    time.Duration
+	timeout     time.Duration
+	maxAttempts int
+	now         func() time.Time
+}
+
+// NewWebhookDeliverer gives every attempt timeout to complete.
+func NewWebhookDeliverer(
+	repo entity.IWebhookRepository, sender entity.IWebhookSender, logger logger.ILogger,
+	interval, timeout time.Duration, maxAttempts int,
+) *WebhookDeliverer {
+	return &WebhookDeliverer{
+		repo:        repo,
+		sender:      sender,
+		logger:      logger,
+		interval:    interval,
+		timeout:     timeout,
+		maxAttempts: maxAttempts,
+		now:         time.Now,
+	}
+}
+
+// DeliverOnce attempts one batch of due webhooks, concurrently, and reports
+// how many were attempted. The batch is leased for twice the timeout, which
+// the attempts cannot outlast.
+func (d *WebhookDeliverer) DeliverOnce(ctx context.Context) (int, error) {
+	deliveries, err := d.repo.ClaimDue(ctx, d.now(), 2*d.timeout, deliverBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	subscriptions := make(map[uuid.UUID]entity.WebhookSubscription)
+	for _, delivery := range deliveries {
+		if _, ok := subscriptions[delivery.SubscriptionID]; ok {
+			continue
+		}
+		subscription, err := d.repo.GetSubscription(ctx, delivery.SubscriptionID)
+		if err != nil {
+			return 0, err
+		}
+		subscriptions[delivery.SubscriptionID] = subscription
+	}
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *entity.WebhookDelivery) {
+			defer wg.Done()
+			d.attempt(ctx, subscriptions[delivery.SubscriptionID], delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+
+	for _, delivery := range deliveries {
+		if err := d.repo.RecordAttempt(ctx, delivery); err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attempt sends delivery and updates it with the outcome.
+func (d *WebhookDeliverer) attempt(
+	ctx context.Context, subscription entity.WebhookSubscription, delivery *entity.WebhookDelivery,
+) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	statusCode, err := d.sender.Send(ctx, subscription, *delivery)
+	now := d.now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+	if err == nil {
+		delivery.Status = entity.WebhookDeliveryDelivered
+		delivery.DeliveredAt = now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.maxAttempts {
+		delivery.Status = entity.WebhookDeliveryDead
+		d.logger.Error(fmt.Sprintf(
+			"Giving up on webhook delivery %s after %d attempts: %s", delivery.ID, delivery.Attempts, err.Error(),
+		))
+		return
+	}
+	delivery.NextAttemptAt = now.Add(webhookRetryDelay(delivery.Attempts))
+}
+
+// webhookRetryDelay is how long to wait after the given number of failed
+// attempts.
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookRetryMax)
+}
+
+// Run delivers on every interval until ctx is done. Full batches are
+// followed by another one right away.
+func (d *WebhookDeliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				attempted, err := d.DeliverOnce(ctx)
+				if err != nil {
+					d.logger.Error(fmt.Sprintf("Error delivering webhooks: %s", err.Error()))
+				}
+				if err != nil || attempted < deliverBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"clean-go-rest-api/internal/crosscutting/logger"
+	"clean-go-rest-api/internal/domain/domainerr"
+	"clean-go-rest-api/internal/domain/dto"
+	"clean-go-rest-api/internal/domain/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type subscribeTestCase struct {
+	testName string
+	req      dto.CreateWebhookRequest
+	fields   []string
+}
+
+func TestWebhookUseCase_Subscribe(t *testing.T) {
+	tests_scenarios := []subscribeTestCase{
+		{
+			testName: "Valid Subscription",
+			req: dto.CreateWebhookRequest{
+				URL:        "https://example.com/hooks",
+				EventTypes: []string{entity.UserUpdated, entity.UserCreated, entity.UserUpdated},
+				Secret:     "0123456789abcdef",
+			},
+		},
+		{
+			testName: "Invalid Subscription",
+			req: dto.CreateWebhookRequest{
+				URL:        "ftp://example.com",
+				EventTypes: []string{"user.renamed"},
+				Secret:     "short",
+			},
+			fields: []string{"url", "event_types", "secret"},
+		},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			repo := SetupMockWebhookRepo()
+			useCase := NewWebhookUseCase(repo)
+			subscription, err := useCase.Subscribe(context.Background(), tt.req)
+
+			switch tt.testName {
+			case tests_scenarios[0].testName:
+				assert.NoError(t, err, "should not return an error")
+				assert.Equal(t, []string{entity.UserCreated, entity.UserUpdated}, subscription.EventTypes,
+					"should deduplicate and sort event types",
+				)
+				assert.Contains(t, repo.subscriptions, subscription.ID, "should store the subscription")
+			case tests_scenarios[1].testName:
+				var validation *domainerr.Error
+				assert.ErrorIs(t, err, domainerr.ErrValidation)
+				if assert.ErrorAs(t, err, &validation) {
+					var fields []string
+					for _, field := range validation.Fields {
+						fields = append(fields, field.Field)
+					}
+					assert.Equal(t, tt.fields, fields, "should report every invalid field")
+				}
+				assert.Empty(t, repo.subscriptions, "should not store invalid subscriptions")
+			}
+		})
+	}
+}
+
+func TestWebhookUseCase_DeliveriesUnknownSubscription(t *testing.T) {
+	useCase := NewWebhookUseCase(SetupMockWebhookRepo())
+	_, err := useCase.Deliveries(context.Background(), uuid.New())
+
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+}
+
+func TestWebhookPublisher_Publish(t *testing.T) {
+	repo := SetupMockWebhookRepo()
+	subscribed := entity.WebhookSubscription{ID: uuid.New(), EventTypes: []string{entity.UserCreated}}
+	other := entity.WebhookSubscription{ID: uuid.New(), EventTypes: []string{entity.UserDeleted}}
+	repo.subscriptions[subscribed.ID] = subscribed
+	repo.subscriptions[other.ID] = other
+
+	user := entity.User{ID: uuid.New(), Name: "John Doe", Email: "john@example.com"}
+	event := entity.NewUserEvent(entity.UserCreated, nil, &user)
+	publisher := NewWebhookPublisher(repo)
+	assert.NoError(t, publisher.Publish(context.Background(), event))
+	assert.NoError(t, publisher.Publish(context.Background(), event), "should accept events published again")
+
+	if assert.Len(t, repo.deliveries, 1, "should queue one delivery per subscription to the event") {
+		delivery := repo.deliveries[0]
+		assert.Equal(t, subscribed.ID, delivery.SubscriptionID)
+		assert.Equal(t, entity.WebhookDeliveryPending, delivery.Status)
+
+		var envelope map[string]json.RawMessage
+		assert.NoError(t, json.Unmarshal(delivery.Body, &envelope))
+		assert.JSONEq(t, `"`+event.ID.String()+`"`, string(envelope["id"]))
+		assert.JSONEq(t, `"user.created"`, string(envelope["type"]))
+		assert.JSONEq(t, string(event.Payload), string(envelope["data"]), "should wrap the event payload")
+	}
+}
+
+// senderMock answers with the status codes of statuses in turn, reporting
+// those outside 2xx as failures.
+type senderMock struct {
+	mu       sync.Mutex
+	statuses []int
+	sent     int
+}
+
+func (s *senderMock) Send(
+	ctx context.Context, subscription entity.WebhookSubscription, delivery entity.WebhookDelivery,
+) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.statuses[min(s.sent, len(s.statuses)-1)]
+	s.sent++
+	if status >= 300 {
+		return status, errors.New("subscriber failed")
+	}
+	return status, nil
+}
+
+type deliverTestCase struct {
+	testName string
+	statuses []int
+	status   entity.WebhookDeliveryStatus
+	attempts int
+}
+
+func TestWebhookDeliverer_DeliverOnce(t *testing.T) {
+	const maxAttempts = 4
+
+	tests_scenarios := []deliverTestCase{
+		{testName: "Delivered", statuses: []int{200}, status: entity.WebhookDeliveryDelivered, attempts: 1},
+		{
+			testName: "Delivered After Retries",
+			statuses: []int{500, 502, 204},
+			status:   entity.WebhookDeliveryDelivered,
+			attempts: 3,
+		},
+		{testName: "Dead Lettered", statuses: []int{500}, status: entity.WebhookDeliveryDead, attempts: maxAttempts},
+	}
+
+	for _, tt := range tests_scenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+			repo := SetupMockWebhookRepo()
+			subscription := entity.WebhookSubscription{ID: uuid.New(), URL: "http://example.com"}
+			repo.subscriptions[subscription.ID] = subscription
+			repo.deliveries = append(repo.deliveries, entity.WebhookDelivery{
+				ID:             uuid.New(),
+				SubscriptionID: subscription.ID,
+				Status:         entity.WebhookDeliveryPending,
+				NextAttemptAt:  now,
+			})
+			sender := &senderMock{statuses: tt.statuses}
+
+			deliverer := NewWebhookDeliverer(repo, sender, logger.NewLogger(), time.Hour, time.Second, maxAttempts)
+			deliverer.now = func() time.Time { return now }
+
+			var delays []time.Duration
+			for repo.deliveries[0].Status == entity.WebhookDeliveryPending {
+				attempted, err := deliverer.DeliverOnce(context.Background())
+				assert.NoError(t, err, "should not return an error")
+				assert.Equal(t, 1, attempted, "should attempt the due delivery")
+
+				attempted, err = deliverer.DeliverOnce(context.Background())
+				assert.NoError(t, err, "should not return an error")
+				assert.Zero(t, attempted, "should not attempt deliveries before they are due")
+
+				if repo.deliveries[0].Status == entity.WebhookDeliveryPending {
+					delay := repo.deliveries[0].NextAttemptAt.Sub(now)
+					delays = append(delays, delay)
+					now = now.Add(delay)
+				}
+			}
+
+			delivery := repo.deliveries[0]
+			assert.Equal(t, tt.status, delivery.Status)
+			assert.Equal(t, tt.attempts, delivery.Attempts, "should count every attempt")
+			assert.Equal(t, tt.attempts, sender.sent)
+			assert.Equal(t, tt.statuses[min(tt.attempts, len(tt.statuses))-1], delivery.LastStatusCode)
+
+			switch tt.testName {
+			case tests_scenarios[0].testName:
+				assert.Equal(t, now, delivery.DeliveredAt, "should record when it was delivered")
+				assert.Empty(t, delivery.LastError)
+			case tests_scenarios[1].testName:
+				assert.Equal(t, []time.Duration{10 * time.Second, 20 * time.Second}, delays,
+					"should back off exponentially",
+				)
+			case tests_scenarios[2].testName:
+				assert.Equal(t, "subscriber failed", delivery.LastError)
+				assert.True(t, delivery.DeliveredAt.IsZero(), "should not record dead deliveries as delivered")
+			}
+		})
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	assert.Equal(t, 10*time.Second, webhookRetryDelay(1))
+	assert.Equal(t, 80*time.Second, webhookRetryDelay(4))
+	assert.Equal(t, time.Hour, webhookRetryDelay(20), "should cap the delay")
+}